@@ -0,0 +1,108 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"html"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/GalaIO/wiz_export/wiz"
+)
+
+const (
+	ankiFile  = "anki.txt"
+	ankiMedia = "anki_media"
+)
+
+type ankiCard struct {
+	front string
+	back  string
+	tags  string
+}
+
+var (
+	ankiMu    sync.Mutex
+	ankiCards []ankiCard
+)
+
+// exportAnki splits the doc into question/answer cards at every heading, the heading is the
+// question and the text below it the answer. Resources are downloaded to the flat media
+// folder prefixed by the docGuid, ready to be copied into the anki collection.media.
+func exportAnki(wizUser *WizUser, doc *Doc, markdown string) error {
+	mediaDir := filepath.Join(exportRoot, ankiMedia)
+	if err := os.MkdirAll(mediaDir, 0755); err != nil {
+		return WrapErr("MkdirAll anki media", err)
+	}
+	for _, fname := range docResources(markdown) {
+		fname := fname
+		resPath := filepath.Join(mediaDir, doc.DocGuid+"_"+fname)
+		runTask(resTask(resPath, wizUser, doc, fname))
+	}
+
+	tags := ankiTag(doc.Category)
+	var cards []ankiCard
+	for _, sec := range splitSections(markdown, 6) {
+		back := strings.TrimSpace(sec.body)
+		if back == "" {
+			continue
+		}
+		front := sec.title
+		if sec.level == 0 {
+			front = doc.Title
+		}
+		cards = append(cards, ankiCard{front: ankiHTML(doc, front), back: ankiHTML(doc, back), tags: tags})
+	}
+	logInfof("Anki:\n\tcards: %v\n", len(cards))
+
+	ankiMu.Lock()
+	defer ankiMu.Unlock()
+	ankiCards = append(ankiCards, cards...)
+	return nil
+}
+
+// ankiHTML escapes the markdown text for the html field, image references point to the media files.
+func ankiHTML(doc *Doc, text string) string {
+	text = html.EscapeString(text)
+	text = wiz.ResourceRe.ReplaceAllString(text, `<img src="`+doc.DocGuid+`_$1">`)
+	return strings.ReplaceAll(text, "\n", "<br>")
+}
+
+// ankiTag turns the category like /日记/2024/ into a hierarchical tag 日记::2024.
+func ankiTag(category string) string {
+	parts := strings.FieldsFunc(category, func(r rune) bool { return r == '/' })
+	return strings.ReplaceAll(strings.Join(parts, "::"), " ", "_")
+}
+
+// writeAnki writes the collected cards as a tab separated anki import file.
+func writeAnki() error {
+	ankiMu.Lock()
+	defer ankiMu.Unlock()
+
+	var buf bytes.Buffer
+	buf.WriteString("#separator:tab\n#html:true\n#tags column:3\n")
+	w := csv.NewWriter(&buf)
+	w.Comma = '\t'
+	for _, card := range ankiCards {
+		if err := w.Write([]string{card.front, card.back, card.tags}); err != nil {
+			return WrapErr("write card", err)
+		}
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return WrapErr("write cards", err)
+	}
+	file := filepath.Join(exportRoot, ankiFile)
+	exported, copied := maskDoc(buf.Bytes())
+	if err := atomicWriteFile(file, exported, 0644); err != nil {
+		return WrapErr("WriteFile anki", err)
+	}
+	if err := writeRedacted(file, copied); err != nil {
+		return WrapErr("WriteFile anki", err)
+	}
+	logInfof("Anki info:\n\tfile: %s\n\tcards: %v\n\tmedia: %s, copy it into the anki collection.media\n",
+		file, len(ankiCards), filepath.Join(exportRoot, ankiMedia))
+	return nil
+}
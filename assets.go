@@ -0,0 +1,82 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/GalaIO/wiz_export/wiz"
+)
+
+const (
+	assetsMapFile = "assets_map.json"
+	// the resources of a doc are in <doc name>.assets next to it
+	assetsSuffix = ".assets"
+)
+
+// the resource links of the wiz html, like <img src="index_files/a.png">
+var htmlResRe = regexp.MustCompile(`((?:src|href)=["'])index_files/`)
+
+var (
+	assetsMu sync.Mutex
+	// local resource path relative to the output -> wiz download url
+	assetsMap = make(map[string]string)
+)
+
+func recordAsset(resPath, resURL string) {
+	assetsMu.Lock()
+	defer assetsMu.Unlock()
+	assetsMap[relPath(resPath)] = resURL
+}
+
+// saveAssetsMap merges the resources of this run into assets_map.json.
+func saveAssetsMap(root string) error {
+	file := filepath.Join(root, assetsMapFile)
+	all := make(map[string]string)
+	if bs, err := ioutil.ReadFile(file); err == nil {
+		if err := json.Unmarshal(bs, &all); err != nil {
+			return WrapErr("Unmarshal assets map", err)
+		}
+	} else if !os.IsNotExist(err) {
+		return WrapErr("read assets map", err)
+	}
+
+	assetsMu.Lock()
+	for local, remote := range assetsMap {
+		all[local] = remote
+	}
+	assetsMu.Unlock()
+	bs, err := json.MarshalIndent(all, "", "  ")
+	if err != nil {
+		return WrapErr("Marshal assets map", err)
+	}
+	if err := atomicWriteFile(file, bs, 0644); err != nil {
+		return WrapErr("WriteFile assets map", err)
+	}
+	return nil
+}
+
+// assetsDir returns the name of the resource folder of the doc file.
+func assetsDir(docPath string) string {
+	return sanitizeFileName(strings.TrimSuffix(filepath.Base(docPath), ".md") + assetsSuffix)
+}
+
+// rewriteAssets points the index_files references of the wiz html to the resource folder dir.
+func rewriteAssets(markdown, dir string) string {
+	return wiz.ResourceRe.ReplaceAllStringFunc(markdown, func(m string) string {
+		i := strings.Index(m, "](index_files/")
+		fname := strings.TrimSuffix(m[i+len("](index_files/"):], ")")
+		return m[:i] + "](<" + dir + "/" + fname + ">)"
+	})
+}
+
+// rewriteHTMLAssets points the index_files links of the wiz html to the resource folder dir.
+func rewriteHTMLAssets(html []byte, dir string) []byte {
+	dir = strings.ReplaceAll(url.PathEscape(dir), "$", "$$")
+	return htmlResRe.ReplaceAll(html, []byte("${1}"+dir+"/"))
+}
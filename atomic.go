@@ -0,0 +1,31 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// atomicWriteFile writes a temp file in the same folder renamed to the file once complete, so
+// that an interrupted export or a full disk leaves no half written files. The temp file is
+// removed if any step fails. The temp name is short, the file name may already be at the 255 bytes limit.
+func atomicWriteFile(file string, data []byte, perm os.FileMode) error {
+	f, err := os.CreateTemp(filepath.Dir(file), ".wiz-*.tmp")
+	if err != nil {
+		return err
+	}
+	tmp := f.Name()
+	_, err = f.Write(data)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Chmod(tmp, perm)
+	}
+	if err == nil {
+		err = os.Rename(tmp, file)
+	}
+	if err != nil {
+		os.Remove(tmp)
+	}
+	return err
+}
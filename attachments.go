@@ -0,0 +1,136 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/GalaIO/wiz_export/wiz"
+)
+
+const attachmentsDir = "attachments"
+
+// Attachment is a wiz attachment with its local file name.
+type Attachment struct {
+	*wiz.Attachment
+	// file is the local file name, unique in the attachments folder
+	file string
+	// remote is the wiz url of an attachment over --max-res-size, which is not downloaded
+	remote string
+}
+
+var (
+	attachmentsMu sync.Mutex
+	// attachment files taken in this run relative to the output, so that docs of a folder don't
+	// overwrite each other
+	attachmentFiles = make(map[string]bool)
+	// attachmentOwners are the docs of the attachment files in the last manifest, kept for them
+	// when they are skipped or exported again
+	attachmentOwners = make(map[string]string)
+)
+
+// reserveAttachments keeps the attachment files of the last export for their docs, so that the
+// docs skipped in this run keep their files and the others keep their suffixes.
+func reserveAttachments(m *Manifest) {
+	attachmentsMu.Lock()
+	defer attachmentsMu.Unlock()
+	attachmentOwners = make(map[string]string)
+	for _, entry := range m.Docs {
+		for _, res := range entry.Resources {
+			if path.Base(path.Dir(res)) == attachmentsDir {
+				attachmentOwners[res] = entry.DocGuid
+			}
+		}
+	}
+}
+
+func fetchAttachments(ctx context.Context, wizUser *WizUser, doc *Doc) ([]*Attachment, error) {
+	list, err := taskClient(ctx, wizUser).Attachments(doc.DocGuid)
+	if err != nil {
+		return nil, err
+	}
+	atts := make([]*Attachment, len(list))
+	for i, att := range list {
+		atts[i] = &Attachment{Attachment: att}
+	}
+	return atts, nil
+}
+
+// claimAttachments names the attachment files of the doc in the attachments folder under dir,
+// sanitized and suffixed like a(1).pdf if taken in this run or by another doc last time.
+func claimAttachments(dir, docGuid string, atts []*Attachment) {
+	attachmentsMu.Lock()
+	defer attachmentsMu.Unlock()
+	taken := func(name string) bool {
+		rel := relPath(filepath.Join(dir, attachmentsDir, name))
+		owner, ok := attachmentOwners[rel]
+		return attachmentFiles[rel] || ok && owner != docGuid
+	}
+	for _, att := range atts {
+		name := sanitizeFileName(att.Name)
+		ext := path.Ext(name)
+		base := strings.TrimSuffix(name, ext)
+		for i := 1; taken(name); i++ {
+			name = fmt.Sprintf("%s(%d)%s", base, i, ext)
+		}
+		attachmentFiles[relPath(filepath.Join(dir, attachmentsDir, name))] = true
+		att.file = name
+	}
+}
+
+// downloadedAttachments returns the attachments to download, without those kept in wiz.
+func downloadedAttachments(atts []*Attachment) []*Attachment {
+	var kept []*Attachment
+	for _, att := range atts {
+		if att.remote == "" {
+			kept = append(kept, att)
+		}
+	}
+	return kept
+}
+
+// attachmentList returns the markdown links of the attachments, appended to the doc, dir is the
+// attachments folder relative to the file of the list.
+func attachmentList(atts []*Attachment, dir string) string {
+	var sb strings.Builder
+	sb.WriteString("\n\n## 附件\n\n")
+	for _, att := range atts {
+		if att.remote != "" {
+			fmt.Fprintf(&sb, "- [%s](%s)\n", att.Name, att.remote)
+			continue
+		}
+		fmt.Fprintf(&sb, "- [%s](<%s/%s>)\n", att.Name, dir, att.file)
+	}
+	return sb.String()
+}
+
+func attachmentURL(wizUser *WizUser, doc *Doc, att *Attachment) string {
+	return kbClient(wizUser).AttachmentURL(doc.DocGuid, att.AttGuid)
+}
+
+func fetchAttachment(ctx context.Context, attPath string, wizUser *WizUser, doc *Doc, att *Attachment) error {
+	attURL := attachmentURL(wizUser, doc, att)
+	recordAsset(attPath, attURL)
+	data, err := taskClient(ctx, wizUser).Attachment(doc.DocGuid, att.AttGuid, *resAccept)
+	if err != nil {
+		return err
+	}
+	if err := writeFile(attPath, data); err != nil {
+		return WrapErr("WriteFile attachment", err)
+	}
+	resDownloaded(doc.DocGuid, attPath)
+	return nil
+}
+
+func attachmentTask(attPath string, wizUser *WizUser, doc *Doc, att *Attachment) *retryTask {
+	return &retryTask{
+		name: "attachment " + doc.DocGuid + "/" + att.Name,
+		run: func(ctx context.Context) error {
+			return fetchAttachment(ctx, attPath, wizUser, doc, att)
+		},
+		res: &FailedRes{URL: attachmentURL(wizUser, doc, att), Path: relPath(attPath)},
+	}
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/GalaIO/wiz_export/wiz"
+)
+
+func TestClaimAttachmentsIncremental(t *testing.T) {
+	f := newFakeWiz(t)
+	modified := int(time.Now().Add(-time.Hour).UnixNano() / int64(time.Millisecond))
+	doc1 := &Doc{DocGuid: "doc1", Title: "One", Category: "/a/", AttachmentCount: 1, Modified: modified}
+	doc2 := &Doc{DocGuid: "doc2", Title: "Two", Category: "/a/", AttachmentCount: 1, Modified: modified}
+	f.addDoc(doc1, "<p>one</p>")
+	f.addDoc(doc2, "<p>two</p>")
+	f.attachments["doc1"] = []*wiz.Attachment{{AttGuid: "att1", Name: "a.pdf"}}
+	f.attachments["doc2"] = []*wiz.Attachment{{AttGuid: "att2", Name: "a.pdf"}}
+	out := setupExport(t, f, map[string]string{"folders": "/a/", "incremental": "true", "concurrency": "1"})
+
+	if status := exportKb(f.user(), time.Now()); status == nil || status.Counts.Exported != 2 {
+		t.Fatalf("first exportKb status = %+v", status)
+	}
+	dir := filepath.Join(out, "a", attachmentsDir)
+	if got := readFile(t, filepath.Join(dir, "a.pdf")); got != "attachment doc1/att1" {
+		t.Fatalf("a.pdf = %q, want the attachment of doc1", got)
+	}
+
+	// doc1 is skipped unchanged, doc2 changed keeps its suffixed file
+	doc2.Modified = int(time.Now().Add(time.Hour).UnixNano() / int64(time.Millisecond))
+	resetKb()
+	status := exportKb(f.user(), time.Now())
+	if status == nil || status.Counts.Exported != 1 || status.Counts.Skipped != 1 {
+		t.Fatalf("second exportKb status = %+v", status)
+	}
+	if got := readFile(t, filepath.Join(dir, "a.pdf")); got != "attachment doc1/att1" {
+		t.Errorf("a.pdf = %q, overwritten by the changed doc", got)
+	}
+	if got := readFile(t, filepath.Join(dir, "a(1).pdf")); got != "attachment doc2/att2" {
+		t.Errorf("a(1).pdf = %q, want the attachment of doc2", got)
+	}
+}
+
+func TestAttachmentsSplitDoc(t *testing.T) {
+	f := newFakeWiz(t)
+	f.addDoc(&Doc{DocGuid: "doc1", Title: "Split", Category: "/a/", AttachmentCount: 1},
+		"<h2>First</h2><p>one</p><h2>Second</h2><p>two</p>")
+	f.attachments["doc1"] = []*wiz.Attachment{{AttGuid: "att1", Name: "a.pdf"}}
+	out := setupExport(t, f, map[string]string{"folders": "/a/", "split-by-heading": "2"})
+
+	if status := exportKb(f.user(), time.Now()); status == nil || status.Counts.Exported != 1 {
+		t.Fatalf("exportKb status = %+v", status)
+	}
+	parts, err := filepath.Glob(filepath.Join(out, "a", "Split", "*.md"))
+	if err != nil || len(parts) < 2 {
+		t.Fatalf("parts = %v, err: %v", parts, err)
+	}
+	last := readFile(t, parts[len(parts)-1])
+	if !strings.Contains(last, "[a.pdf](<../attachments/a.pdf>)") {
+		t.Errorf("last part %q without the attachment link of its folder", last)
+	}
+	for _, part := range parts[:len(parts)-1] {
+		if strings.Contains(readFile(t, part), "a.pdf") {
+			t.Errorf("part %s links the attachment", part)
+		}
+	}
+	if got := readFile(t, filepath.Join(out, "a", attachmentsDir, "a.pdf")); got != "attachment doc1/att1" {
+		t.Errorf("a.pdf = %q", got)
+	}
+}
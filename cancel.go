@@ -0,0 +1,35 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/signal"
+)
+
+var (
+	// exportCtx is canceled by Ctrl+C, stopping the requests in flight
+	exportCtx, cancelExport = context.WithCancel(context.Background())
+
+	errInterrupted = errors.New("export interrupted")
+)
+
+// watchInterrupt cancels the export on the first Ctrl+C, the progress is saved as the export
+// stops. A second Ctrl+C quits at once.
+func watchInterrupt() {
+	ch := make(chan os.Signal, 2)
+	signal.Notify(ch, os.Interrupt)
+	go func() {
+		<-ch
+		logErrorf("Interrupted:\n\tstopping the export, press Ctrl+C again to quit now\n")
+		cancelExport()
+		// paused downloads have to see the cancel
+		setPaused(false)
+		<-ch
+		os.Exit(130)
+	}()
+}
+
+func interrupted() bool {
+	return exportCtx.Err() != nil
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"flag"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+var (
+	recursive  = flag.Bool("recursive", false, "export the sub folders of the folders too, keeping the hierarchy")
+	allFolders = flag.Bool("all", false, "export all the folders of the kb, the default without folders and collections")
+)
+
+// fetchCategories fetches all the folders of the kb, like /日记/2023/.
+func fetchCategories(wizUser *WizUser) ([]string, error) {
+	return kbClient(wizUser).Categories()
+}
+
+func sortedFolders(categories []string) []string {
+	sorted := append([]string(nil), categories...)
+	sort.Strings(sorted)
+	return sorted
+}
+
+// expandFolders adds the sub folders of the folders from all the categories, each folder once
+// and parents before children.
+func expandFolders(folders, categories []string) []string {
+	seen := make(map[string]bool)
+	var expanded []string
+	add := func(folder string) {
+		if !seen[folder] {
+			seen[folder] = true
+			expanded = append(expanded, folder)
+		}
+	}
+	sorted := sortedFolders(categories)
+	for _, folder := range folders {
+		add(folder)
+		for _, category := range sorted {
+			if category != folder && strings.HasPrefix(category, folder) {
+				add(category)
+			}
+		}
+	}
+	return expanded
+}
+
+// exportAll reports whether to export all the folders, asked or with nothing else to export.
+func exportAll() bool {
+	return *allFolders || (*folders == "" && *collections == "" && *docGuids == "" && *retryRes == "")
+}
+
+// normalizeFolder returns the wiz folder of the flag with one leading and trailing slash,
+// like /日记/2024/ for 日记/2024 or //日记//2024.
+func normalizeFolder(folder string) string {
+	var segs []string
+	for _, seg := range strings.Split(strings.TrimSpace(folder), "/") {
+		if seg != "" {
+			segs = append(segs, seg)
+		}
+	}
+	if len(segs) == 0 {
+		return "/"
+	}
+	return "/" + strings.Join(segs, "/") + "/"
+}
+
+// folderPath returns the local folder of the wiz folder under root, each level sanitized
+// like the file names and no empty levels.
+func folderPath(root, folder string) string {
+	elems := []string{root}
+	for _, seg := range strings.Split(folder, "/") {
+		if seg != "" {
+			elems = append(elems, sanitizeFileName(seg))
+		}
+	}
+	return filepath.Join(elems...)
+}
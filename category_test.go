@@ -0,0 +1,30 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestNormalizeFolder(t *testing.T) {
+	tests := []struct {
+		folder string
+		want   string
+		path   string
+	}{
+		{"/a/", "/a/", "a"},
+		{"/a/b/", "/a/b/", "a/b"},
+		{"a/b", "/a/b/", "a/b"},
+		{" //a//b ", "/a/b/", "a/b"},
+		{"/", "/", ""},
+	}
+	root := filepath.Join("out", "kb")
+	for _, tt := range tests {
+		folder := normalizeFolder(tt.folder)
+		if folder != tt.want {
+			t.Errorf("normalizeFolder(%q) = %q, want %q", tt.folder, folder, tt.want)
+		}
+		if got, want := folderPath(root, folder), filepath.Join(root, filepath.FromSlash(tt.path)); got != want {
+			t.Errorf("folderPath(%q, %q) = %q, want %q", root, folder, got, want)
+		}
+	}
+}
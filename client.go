@@ -0,0 +1,138 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"flag"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"sync/atomic"
+	"time"
+
+	"github.com/GalaIO/wiz_export/wiz"
+)
+
+var (
+	clientCert = flag.String("client-cert", "", "client certificate file (PEM) for mTLS")
+	clientKey  = flag.String("client-key", "", "client key file (PEM) for mTLS")
+	timeout    = flag.Duration("timeout", 30*time.Second, "timeout to connect, to get the response headers and between reads of the body, "+
+		"timed out requests are retried, 0 means none")
+	proxy = flag.String("proxy", "", "proxy of the requests, like http://127.0.0.1:8080, default HTTP_PROXY/HTTPS_PROXY")
+
+	// httpClient sends the requests of wizClient and the HEAD probes
+	httpClient wiz.Doer = http.DefaultClient
+)
+
+// setupClient builds httpClient from the flags: the timeout, the proxy and the client certificate if set.
+// The timeout is no deadline of the whole request, large resources take as long as they keep coming.
+func setupClient() error {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if *timeout > 0 {
+		transport.DialContext = (&net.Dialer{Timeout: *timeout, KeepAlive: 30 * time.Second}).DialContext
+		transport.TLSHandshakeTimeout = *timeout
+		transport.ResponseHeaderTimeout = *timeout
+	}
+	transport.Proxy = http.ProxyFromEnvironment
+	if *proxy != "" {
+		proxyURL, err := url.Parse(*proxy)
+		if err != nil {
+			return WrapErr("parse proxy", err)
+		}
+		transport.Proxy = http.ProxyURL(proxyURL)
+	}
+	if *clientCert != "" || *clientKey != "" {
+		cert, err := tls.LoadX509KeyPair(*clientCert, *clientKey)
+		if err != nil {
+			return WrapErr("load client cert", err)
+		}
+		transport.TLSClientConfig = &tls.Config{Certificates: []tls.Certificate{cert}}
+	}
+	var rt http.RoundTripper = transport
+	if *timeout > 0 {
+		rt = &idleTimeoutTransport{base: transport, timeout: *timeout}
+	}
+	httpClient = &http.Client{Transport: rt}
+	return nil
+}
+
+// idleTimeoutTransport cancels the requests whose body stalls, no bytes read for the timeout.
+type idleTimeoutTransport struct {
+	base    http.RoundTripper
+	timeout time.Duration
+}
+
+func (t *idleTimeoutTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx, cancel := context.WithCancel(req.Context())
+	resp, err := t.base.RoundTrip(req.WithContext(ctx))
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	body := &idleTimeoutBody{ReadCloser: resp.Body, cancel: cancel, timeout: t.timeout}
+	body.timer = time.AfterFunc(t.timeout, body.expire)
+	resp.Body = body
+	return resp, nil
+}
+
+type idleTimeoutBody struct {
+	io.ReadCloser
+	cancel  context.CancelFunc
+	timeout time.Duration
+	timer   *time.Timer
+	expired int32
+}
+
+func (b *idleTimeoutBody) expire() {
+	atomic.StoreInt32(&b.expired, 1)
+	b.cancel()
+}
+
+func (b *idleTimeoutBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if atomic.LoadInt32(&b.expired) == 1 {
+		return n, errIdleTimeout
+	}
+	b.timer.Reset(b.timeout)
+	return n, err
+}
+
+func (b *idleTimeoutBody) Close() error {
+	b.timer.Stop()
+	b.cancel()
+	return b.ReadCloser.Close()
+}
+
+// errIdleTimeout is a net.Error timeout, retried like the other timeouts.
+var errIdleTimeout error = idleTimeoutError{}
+
+type idleTimeoutError struct{}
+
+func (idleTimeoutError) Error() string   { return "read body: idle timeout" }
+func (idleTimeoutError) Timeout() bool   { return true }
+func (idleTimeoutError) Temporary() bool { return true }
+
+// wizClient calls the wiz apis through Fetch, with the retries and limits of the flags
+var wizClient = wiz.NewClient(wiz.DefaultServer).WithContext(exportCtx)
+
+// setupWizClient configures wizClient from the flags.
+func setupWizClient() {
+	wizClient = wiz.NewClient(*server).WithContext(exportCtx)
+	wizClient.HTTPClient = httpClient
+	wizClient.LoginFields = loginRenames()
+	wizClient.VerifyField = *verifyField
+	wizClient.Fetch = fetchAccept
+	wizClient.Converter = conv
+}
+
+// taskClient returns the client of the kb of the user whose requests are canceled with ctx,
+// the retry budget of a task.
+func taskClient(ctx context.Context, wizUser *WizUser) *wiz.Client {
+	return kbClient(wizUser).WithContext(ctx)
+}
+
+// kbClient returns the client of the kb of the user.
+func kbClient(wizUser *WizUser) *wiz.Client {
+	return wizClient.WithUser(wizUser)
+}
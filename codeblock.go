@@ -0,0 +1,207 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	md "github.com/JohannesKaufmann/html-to-markdown"
+	"github.com/PuerkitoBio/goquery"
+	"golang.org/x/net/html"
+)
+
+// tables wrapping a gutter column and a code column
+const codeTableSelector = "td.code, td.hljs-ln-code"
+
+var (
+	// line number gutters rendered by the common highlighters
+	gutterClasses = []string{"gutter", "linenos", "lineno", "linenodiv", "line-number", "line-numbers-rows",
+		"hljs-ln-numbers", "CodeMirror-gutters", "CodeMirror-linenumber"}
+	gutterSelector   = "." + strings.Join(gutterClasses, ", .")
+	highlightClasses = []string{"hll", "highlight-line", "highlighted", "line-highlight", "marked", "mark"}
+)
+
+// codeBlocks converts pre blocks from the raw text of the code so indents and line breaks
+// are kept, line number gutters are dropped and highlighted lines are written to
+// the fence info as `hl_lines="2 4"`.
+func codeBlocks() md.Plugin {
+	return func(c *md.Converter) []md.Rule {
+		c.Before(unwrapCodeTables)
+		return []md.Rule{{
+			Filter: []string{"pre"},
+			Replacement: func(content string, selec *goquery.Selection, opt *md.Options) *string {
+				if selec.ParentsFiltered(gutterSelector).Length() > 0 {
+					return md.String("")
+				}
+				code, hlLines := codeText(selec)
+				hlLines = append(hlLines, parseLineRanges(selec.AttrOr("data-line", ""))...)
+
+				info := codeLanguage(selec)
+				if len(hlLines) > 0 {
+					info = strings.TrimSpace(info + " " + formatHlLines(hlLines))
+				}
+				fenceChar, _ := utf8.DecodeRuneInString(opt.Fence)
+				fence := md.CalculateCodeFence(fenceChar, code)
+				text := "\n\n" + fence + info + "\n" + code + "\n" + fence + "\n\n"
+				return &text
+			},
+		}}
+	}
+}
+
+// unwrapCodeTables replaces `<table><td class="gutter"/><td class="code"><pre/></td></table>`
+// with the inner pre, otherwise the table plugin turns it into a markdown table.
+func unwrapCodeTables(selec *goquery.Selection) {
+	selec.Find(codeTableSelector).Each(func(i int, s *goquery.Selection) {
+		pre := s.Find("pre").First()
+		table := s.Closest("table")
+		if pre.Length() == 0 || table.Length() == 0 || table.ParentsFiltered("pre").Length() > 0 {
+			return
+		}
+		table.ReplaceWithSelection(pre)
+	})
+}
+
+// codeText returns the text of the code block and the 1-based highlighted lines.
+func codeText(selec *goquery.Selection) (string, []int) {
+	var b strings.Builder
+	lines := 0
+	var hlLines []int
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		switch n.Type {
+		case html.TextNode:
+			b.WriteString(n.Data)
+			lines += strings.Count(n.Data, "\n")
+			return
+		case html.ElementNode:
+			if isGutter(n) {
+				return
+			}
+			if n.Data == "br" {
+				b.WriteString("\n")
+				lines++
+				return
+			}
+		}
+		start := lines
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+		if n.Type != html.ElementNode {
+			return
+		}
+		// one element per line, like <div class="line">
+		if isBlock(n) && !strings.HasSuffix(b.String(), "\n") {
+			b.WriteString("\n")
+			lines++
+		}
+		if isHighlighted(n) {
+			end := lines
+			if strings.HasSuffix(b.String(), "\n") {
+				end--
+			}
+			for l := start; l <= end; l++ {
+				hlLines = append(hlLines, l+1)
+			}
+		}
+	}
+	for _, n := range selec.Nodes {
+		walk(n)
+	}
+	return strings.TrimSuffix(b.String(), "\n"), hlLines
+}
+
+func isGutter(n *html.Node) bool {
+	if _, ok := attr(n, "data-line-number"); ok && n.FirstChild == nil {
+		return true
+	}
+	return hasClass(n, gutterClasses)
+}
+
+func isHighlighted(n *html.Node) bool {
+	return hasClass(n, highlightClasses)
+}
+
+func isBlock(n *html.Node) bool {
+	switch n.Data {
+	case "div", "p", "li", "tr":
+		return true
+	}
+	return false
+}
+
+func hasClass(n *html.Node, names []string) bool {
+	class, _ := attr(n, "class")
+	for _, c := range strings.Fields(class) {
+		for _, name := range names {
+			if c == name {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func attr(n *html.Node, key string) (string, bool) {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return a.Val, true
+		}
+	}
+	return "", false
+}
+
+// codeLanguage reads the language from `language-xx`/`lang-xx` classes or data-lang attributes.
+func codeLanguage(selec *goquery.Selection) string {
+	for _, s := range []*goquery.Selection{selec.Find("code").First(), selec} {
+		for _, c := range strings.Fields(s.AttrOr("class", "")) {
+			for _, prefix := range []string{"language-", "lang-"} {
+				if strings.HasPrefix(c, prefix) {
+					return strings.TrimPrefix(c, prefix)
+				}
+			}
+		}
+		for _, key := range []string{"data-lang", "data-language", "lang"} {
+			if lang := strings.TrimSpace(s.AttrOr(key, "")); lang != "" {
+				return lang
+			}
+		}
+	}
+	return ""
+}
+
+// parseLineRanges parses prism style line ranges like `2,4-5`.
+func parseLineRanges(s string) []int {
+	var lines []int
+	for _, part := range strings.Split(s, ",") {
+		bounds := strings.SplitN(strings.TrimSpace(part), "-", 2)
+		from, err := strconv.Atoi(bounds[0])
+		if err != nil {
+			continue
+		}
+		to := from
+		if len(bounds) == 2 {
+			if to, err = strconv.Atoi(bounds[1]); err != nil {
+				continue
+			}
+		}
+		for l := from; l <= to; l++ {
+			lines = append(lines, l)
+		}
+	}
+	return lines
+}
+
+func formatHlLines(lines []int) string {
+	sort.Ints(lines)
+	var strs []string
+	for i, l := range lines {
+		if i == 0 || l != lines[i-1] {
+			strs = append(strs, strconv.Itoa(l))
+		}
+	}
+	return fmt.Sprintf(`hl_lines="%s"`, strings.Join(strs, " "))
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	md "github.com/JohannesKaufmann/html-to-markdown"
+)
+
+func TestCodeBlocks(t *testing.T) {
+	tests := []struct {
+		name string
+		html string
+		want string
+	}{
+		{"plain", `<pre>a := 1
+  b := 2</pre>`, "```\na := 1\n  b := 2\n```"},
+		{"language class", `<pre><code class="language-go">x</code></pre>`, "```go\nx\n```"},
+		{"lang class", `<pre class="lang-js">x</pre>`, "```js\nx\n```"},
+		{"data-lang", `<pre data-lang="python">x</pre>`, "```python\nx\n```"},
+		{"line divs", `<pre><div>a</div><div>b</div></pre>`, "```\na\nb\n```"},
+		{"br", `<pre>a<br>b</pre>`, "```\na\nb\n```"},
+		{"highlighted", `<pre class="lang-go"><div>a</div><div class="hll">b</div><div>c</div></pre>`,
+			"```go hl_lines=\"2\"\na\nb\nc\n```"},
+		{"data-line", `<pre data-line="1,3-4">a
+b
+c
+d</pre>`, "```hl_lines=\"1 3 4\"\na\nb\nc\nd\n```"},
+		{"gutter", `<pre><span class="lineno">1</span>a
+<span class="lineno">2</span>b</pre>`, "```\na\nb\n```"},
+		{"code table", `<table><tr><td class="gutter"><pre>1
+2</pre></td><td class="code"><pre>a
+b</pre></td></tr></table>`, "```\na\nb\n```"},
+		{"fence in code", "<pre>```\nx\n```</pre>", "````\n```\nx\n```\n````"},
+	}
+	conv := md.NewConverter("", true, &md.Options{CodeBlockStyle: "fenced"})
+	conv.Use(codeBlocks())
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := conv.ConvertString(tt.html)
+			if err != nil {
+				t.Fatalf("ConvertString: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("convert %q = %q, want %q", tt.html, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseLineRanges(t *testing.T) {
+	tests := []struct {
+		in   string
+		want []int
+	}{
+		{"", nil},
+		{"2", []int{2}},
+		{"2,4-5", []int{2, 4, 5}},
+		{" 1 , x, 3-a, 6-7", []int{1, 6, 7}},
+	}
+	for _, tt := range tests {
+		if got := parseLineRanges(tt.in); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseLineRanges(%q) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+var collections = flag.String("collections", "", "export share collections by id, like id1,id2")
+
+// fetchCollection exports the share collection to a folder named by its title, every group
+// gets a sub folder prefixed with its position and _collection.md lists the docs in order.
+func fetchCollection(root string, wizUser *WizUser, id string) error {
+	c, err := kbClient(wizUser).Collection(id)
+	if err != nil {
+		return err
+	}
+	title := c.Title
+	if title == "" {
+		title = id
+	}
+	dir := filepath.Join(root, sanitizeFileName(title))
+
+	var index bytes.Buffer
+	fmt.Fprintf(&index, "# %s\n", title)
+	for i, group := range c.Groups {
+		groupName := fmt.Sprintf("%02d-%s", i+1, sanitizeFileName(group.Name))
+		groupDir := filepath.Join(dir, groupName)
+		if err := os.MkdirAll(groupDir, 0755); err != nil {
+			return WrapErr("MkdirAll collection group", err)
+		}
+		logDebugf("Collection group:\n\tname: %s\n\tdocs: %v\n", group.Name, len(group.Docs))
+		fmt.Fprintf(&index, "\n## %s\n\n", group.Name)
+		for j, doc := range group.Docs {
+			if exportStopped() {
+				break
+			}
+			exportDoc(groupDir, wizUser, doc)
+			fmt.Fprintf(&index, "%d. [%s](<%s>)\n", j+1, doc.Title, docLink(dir, groupDir, doc))
+		}
+		if *folderIndex {
+			if err := writeFolderIndex(groupDir, group.Name, "../_collection.md", group.Docs); err != nil {
+				return err
+			}
+		}
+	}
+	if err := atomicWriteFile(filepath.Join(dir, "_collection.md"), index.Bytes(), 0644); err != nil {
+		return WrapErr("WriteFile collection index", err)
+	}
+	return nil
+}
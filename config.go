@@ -0,0 +1,73 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+var configFile = flag.String("config", "", "read the flags from a yaml or json file, like config.yaml, the command line flags win")
+
+// loadConfig sets the flags not given on the command line from --config,
+// keys are the flag names, lists like folders are joined by commas.
+func loadConfig() error {
+	if *configFile == "" {
+		return nil
+	}
+	bs, err := ioutil.ReadFile(*configFile)
+	if os.IsNotExist(err) {
+		return fmt.Errorf("config file not found: %s", *configFile)
+	}
+	if err != nil {
+		return WrapErr("read config", err)
+	}
+	values := make(map[string]interface{})
+	if strings.EqualFold(filepath.Ext(*configFile), ".json") {
+		err = json.Unmarshal(bs, &values)
+	} else {
+		err = yaml.Unmarshal(bs, &values)
+	}
+	if err != nil {
+		return WrapErr("parse config "+*configFile, err)
+	}
+
+	given := make(map[string]bool)
+	flag.Visit(func(f *flag.Flag) {
+		given[f.Name] = true
+	})
+	for name, v := range values {
+		if flag.Lookup(name) == nil {
+			return fmt.Errorf("unknown flag in config %s: %s", *configFile, name)
+		}
+		if given[name] || name == "config" {
+			continue
+		}
+		if err := flag.Set(name, configValue(v)); err != nil {
+			return WrapErr("config "+name, err)
+		}
+	}
+	return nil
+}
+
+func configValue(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case []interface{}:
+		items := make([]string, 0, len(v))
+		for _, item := range v {
+			items = append(items, configValue(item))
+		}
+		return strings.Join(items, ",")
+	}
+	return fmt.Sprint(v)
+}
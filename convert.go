@@ -0,0 +1,196 @@
+package main
+
+import (
+	"flag"
+	"html"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+var (
+	annotateDates = flag.Bool("annotateDates", false, "annotate relative dates like 今天/yesterday with the absolute date from the doc created time")
+
+	relativeDates = map[string]int{"今天": 0, "今日": 0, "昨天": -1, "昨日": -1, "前天": -2, "明天": 1, "明日": 1, "后天": 2,
+		"today": 0, "yesterday": -1, "tomorrow": 1}
+	relativeDateRe = regexp.MustCompile(`(今天|今日|昨天|昨日|前天|明天|明日|后天|\b(?i:today|yesterday|tomorrow)\b)( \(\d{4}-\d{2}-\d{2}\))?`)
+	fenceRe        = regexp.MustCompile("^ {0,3}(```+|~~~+)")
+	headingRe      = regexp.MustCompile(`^ {0,3}(#{1,6})[ \t]+(.*?)(?:[ \t]+#+)?[ \t]*\n?$`)
+)
+
+// unescapeMarkdown drops the escapes the converter adds to the text where markdown doesn't need them:
+// brackets like \[1\], and backslashes not followed by punctuation, so paths like C:\dir and
+// formulas like $\alpha$ stay as written. Code blocks and inline code are kept as is.
+func unescapeMarkdown(markdown string) string {
+	return mapOutsideCode(markdown, func(s string) string {
+		var sb strings.Builder
+		for i := 0; i < len(s); i++ {
+			if s[i] != '\\' || i+1 == len(s) {
+				sb.WriteByte(s[i])
+				continue
+			}
+			i++
+			switch c := s[i]; {
+			case c == '[' || c == ']':
+				sb.WriteByte(c)
+			case c == '\\' && i+1 < len(s) && s[i+1] != '\n' && !isASCIIPunct(s[i+1]):
+				sb.WriteByte(c)
+			default:
+				sb.WriteByte('\\')
+				sb.WriteByte(c)
+			}
+		}
+		return sb.String()
+	})
+}
+
+func isASCIIPunct(c byte) bool {
+	return strings.IndexByte("!\"#$%&'()*+,-./:;<=>?@[\\]^_`{|}~", c) >= 0
+}
+
+// decodeEntities unescapes html entities left in the markdown, the html parser
+// only decodes one level, so double escaped notes still contain `&amp;lt;` etc.
+func decodeEntities(markdown string) string {
+	return mapOutsideCode(markdown, html.UnescapeString)
+}
+
+// annotateRelativeDates appends the absolute date to relative dates rendered by the editor,
+// like 昨天 (2024-01-01), the dates are relative to the doc created time.
+func annotateRelativeDates(markdown string, created time.Time) string {
+	return mapOutsideCode(markdown, func(s string) string {
+		return relativeDateRe.ReplaceAllStringFunc(s, func(m string) string {
+			sub := relativeDateRe.FindStringSubmatch(m)
+			if sub[2] != "" {
+				// annotated already
+				return m
+			}
+			days := relativeDates[strings.ToLower(sub[1])]
+			return m + " (" + created.AddDate(0, 0, days).Format("2006-01-02") + ")"
+		})
+	})
+}
+
+// mapOutsideCode applies fn to the markdown text, skipping fenced code blocks
+// and inline code spans.
+func mapOutsideCode(markdown string, fn func(string) string) string {
+	var out, text strings.Builder
+	for _, line := range markdownLines(markdown) {
+		if !line.code {
+			text.WriteString(line.text)
+			continue
+		}
+		out.WriteString(mapOutsideInlineCode(text.String(), fn))
+		text.Reset()
+		out.WriteString(line.text)
+	}
+	out.WriteString(mapOutsideInlineCode(text.String(), fn))
+	return out.String()
+}
+
+type mdLine struct {
+	text string
+	code bool
+}
+
+// markdownLines splits the markdown after each newline, marking the lines of fenced code blocks.
+func markdownLines(markdown string) []mdLine {
+	var lines []mdLine
+	fence := ""
+	for _, line := range strings.SplitAfter(markdown, "\n") {
+		if fence != "" {
+			lines = append(lines, mdLine{text: line, code: true})
+			if t := strings.TrimSpace(line); strings.HasPrefix(t, fence) && strings.Trim(t, fence[:1]) == "" {
+				fence = ""
+			}
+			continue
+		}
+		if m := fenceRe.FindStringSubmatch(line); m != nil {
+			fence = m[1]
+			lines = append(lines, mdLine{text: line, code: true})
+			continue
+		}
+		lines = append(lines, mdLine{text: line})
+	}
+	return lines
+}
+
+type section struct {
+	// level is 0 for the text before the first heading
+	level int
+	title string
+	body  string
+}
+
+// splitSections splits the markdown at the atx headings with a level up to maxLevel.
+func splitSections(markdown string, maxLevel int) []section {
+	sections := []section{{}}
+	var body strings.Builder
+	for _, line := range markdownLines(markdown) {
+		if !line.code {
+			if m := headingRe.FindStringSubmatch(line.text); m != nil && len(m[1]) <= maxLevel {
+				sections[len(sections)-1].body = body.String()
+				body.Reset()
+				sections = append(sections, section{level: len(m[1]), title: m[2]})
+				continue
+			}
+		}
+		body.WriteString(line.text)
+	}
+	sections[len(sections)-1].body = body.String()
+	return sections
+}
+
+func mapOutsideInlineCode(s string, fn func(string) string) string {
+	var out strings.Builder
+	for {
+		start := strings.IndexByte(s, '`')
+		if start < 0 {
+			break
+		}
+		n := backtickRun(s[start:])
+		end := -1
+		for i := start + n; i < len(s); {
+			j := strings.IndexByte(s[i:], '`')
+			if j < 0 {
+				break
+			}
+			m := backtickRun(s[i+j:])
+			if m == n {
+				end = i + j
+				break
+			}
+			i += j + m
+		}
+		if end < 0 {
+			// unmatched backticks are plain text
+			out.WriteString(fn(s[:start+n]))
+			s = s[start+n:]
+			continue
+		}
+		out.WriteString(fn(s[:start]))
+		out.WriteString(s[start : end+n])
+		s = s[end+n:]
+	}
+	out.WriteString(fn(s))
+	return out.String()
+}
+
+func backtickRun(s string) int {
+	n := 0
+	for n < len(s) && s[n] == '`' {
+		n++
+	}
+	return n
+}
+
+// rawMarkdown returns the markdown source kept in the html of a wiz markdown note.
+func rawMarkdown(page string) (string, error) {
+	dom, err := goquery.NewDocumentFromReader(strings.NewReader(page))
+	if err != nil {
+		return "", err
+	}
+	text, _ := codeText(dom.Find("body"))
+	return strings.TrimSpace(text) + "\n", nil
+}
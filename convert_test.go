@@ -0,0 +1,51 @@
+package main
+
+import "testing"
+
+func TestUnescapeMarkdown(t *testing.T) {
+	tests := []struct {
+		name     string
+		markdown string
+		want     string
+	}{
+		{"brackets", `see \[1\] here`, `see [1] here`},
+		{"path", `C:\\dir\\file`, `C:\dir\file`},
+		{"formula", `$\\alpha$`, `$\alpha$`},
+		{"escaped underscore", `snake\_case`, `snake\_case`},
+		{"escaped backslash before punctuation", `a\\*b`, `a\\*b`},
+		{"trailing backslash", `end\`, `end\`},
+		{"inline code", "run `a\\_b \\[1\\] C:\\\\dir` now \\[2\\]", "run `a\\_b \\[1\\] C:\\\\dir` now [2]"},
+		{"fenced code", "```\n\\[1\\] a\\_b C:\\\\dir\n```\n\\[2\\]", "```\n\\[1\\] a\\_b C:\\\\dir\n```\n[2]"},
+		{"tilde fence", "~~~go\nx := `\\\\`\n~~~\nC:\\\\dir", "~~~go\nx := `\\\\`\n~~~\nC:\\dir"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := unescapeMarkdown(tt.markdown); got != tt.want {
+				t.Errorf("unescapeMarkdown(%q) = %q, want %q", tt.markdown, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecodeEntities(t *testing.T) {
+	tests := []struct {
+		name     string
+		markdown string
+		want     string
+	}{
+		{"plain", "a &lt; b", "a < b"},
+		{"double escaped", "&amp;lt;div&amp;gt;", "&lt;div&gt;"},
+		{"numeric", "&#20013;&#x6587;", "中文"},
+		{"nbsp", "a&nbsp;b", "a\u00a0b"},
+		{"no entity", "a & b", "a & b"},
+		{"inline code", "`&lt;` and &lt;", "`&lt;` and <"},
+		{"fenced code", "```\n&amp;\n```\n&amp;", "```\n&amp;\n```\n&"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := decodeEntities(tt.markdown); got != tt.want {
+				t.Errorf("decodeEntities(%q) = %q, want %q", tt.markdown, got, tt.want)
+			}
+		})
+	}
+}
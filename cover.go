@@ -0,0 +1,56 @@
+package main
+
+import (
+	"context"
+	"net/url"
+	"path"
+	"strings"
+)
+
+// coverName is the file of the doc cover in its resource folder, like cover.jpg.
+func coverName(doc *Doc) string {
+	if doc.CoverImage == "" {
+		return ""
+	}
+	ext := ".jpg"
+	if u, err := url.Parse(doc.CoverImage); err == nil && path.Ext(u.Path) != "" {
+		ext = strings.ToLower(path.Ext(u.Path))
+	}
+	return "cover" + ext
+}
+
+// coverURL returns the url of the cover, a resource of the doc unless a full url.
+func coverURL(wizUser *WizUser, doc *Doc) string {
+	if strings.Contains(doc.CoverImage, "://") {
+		return doc.CoverImage
+	}
+	return resourceURL(wizUser, doc, strings.TrimPrefix(doc.CoverImage, "index_files/"))
+}
+
+// fetchCover downloads the cover of the doc to coverPath, the token is only sent to the kb server.
+func fetchCover(ctx context.Context, coverPath string, wizUser *WizUser, doc *Doc) error {
+	coverURL := coverURL(wizUser, doc)
+	token := ""
+	if strings.HasPrefix(coverURL, wizUser.KbServer) {
+		token = wizUser.CurrentToken()
+	}
+	recordAsset(coverPath, coverURL)
+	data, err := FetchRes(ctx, coverURL, token)
+	if err != nil {
+		return WrapErr("fetch cover", err)
+	}
+	if err := writeFile(coverPath, data); err != nil {
+		return WrapErr("WriteFile cover", err)
+	}
+	return nil
+}
+
+func coverTask(coverPath string, wizUser *WizUser, doc *Doc) *retryTask {
+	return &retryTask{
+		name: "cover " + doc.DocGuid + " " + doc.Title,
+		run: func(ctx context.Context) error {
+			return fetchCover(ctx, coverPath, wizUser, doc)
+		},
+		res: &FailedRes{URL: coverURL(wizUser, doc), Path: relPath(coverPath)},
+	}
+}
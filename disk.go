@@ -0,0 +1,72 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"strconv"
+	"strings"
+	"sync/atomic"
+)
+
+var (
+	maxDisk = flag.String("max-disk", "", "stop the export gracefully before the written docs and resources exceed the size, like 500MB, 2GB")
+
+	errDiskLimit = errors.New("disk limit reached")
+	diskLimit    int64
+	diskWritten  int64
+	diskFull     int32
+)
+
+// parseSize parses sizes like 512, 20KB, 20MB, 2G in 1024 multiples.
+func parseSize(s string) (int64, error) {
+	s = strings.ToUpper(strings.TrimSpace(s))
+	units := []struct {
+		suffix string
+		size   int64
+	}{{"TB", 1 << 40}, {"GB", 1 << 30}, {"MB", 1 << 20}, {"KB", 1 << 10},
+		{"T", 1 << 40}, {"G", 1 << 30}, {"M", 1 << 20}, {"K", 1 << 10}, {"B", 1}}
+	for _, unit := range units {
+		if strings.HasSuffix(s, unit.suffix) {
+			n, err := strconv.ParseFloat(strings.TrimSpace(strings.TrimSuffix(s, unit.suffix)), 64)
+			if err != nil {
+				return 0, err
+			}
+			return int64(n * float64(unit.size)), nil
+		}
+	}
+	return strconv.ParseInt(s, 10, 64)
+}
+
+// writeFile writes the exported content, refusing writes beyond the disk limit.
+func writeFile(file string, data []byte) error {
+	return writeFileCopy(file, data, data)
+}
+
+// writeFileCopy writes data into the export and copied into the copy under --redactOutput,
+// refusing writes beyond the disk limit.
+func writeFileCopy(file string, data, copied []byte) error {
+	if diskLimit > 0 {
+		if atomic.AddInt64(&diskWritten, int64(len(data))) > diskLimit {
+			atomic.AddInt64(&diskWritten, -int64(len(data)))
+			atomic.StoreInt32(&diskFull, 1)
+			return errDiskLimit
+		}
+	} else {
+		atomic.AddInt64(&diskWritten, int64(len(data)))
+	}
+	if err := atomicWriteFile(file, data, 0644); err != nil {
+		return err
+	}
+	return writeRedacted(file, copied)
+}
+
+// exportStopped reports whether the export has to stop, by the disk limit or Ctrl+C,
+// the progress is still saved.
+func exportStopped() bool {
+	return atomic.LoadInt32(&diskFull) == 1 || interrupted()
+}
+
+func printDiskInfo() {
+	logInfof("Disk info:\n\twritten: %v\n\tlimit: %v\n\tstopped: %v\n",
+		atomic.LoadInt64(&diskWritten), diskLimit, exportStopped())
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestDiskLimitFailed(t *testing.T) {
+	f := newFakeWiz(t)
+	f.addDoc(&Doc{DocGuid: "doc1", Title: "Big", Category: "/a/"}, "<p>too big for the disk</p>")
+	out := setupExport(t, f, map[string]string{"folders": "/a/"})
+	diskLimit = 4
+	t.Cleanup(func() {
+		diskLimit = 0
+		atomic.StoreInt64(&diskWritten, 0)
+		atomic.StoreInt32(&diskFull, 0)
+	})
+
+	status := exportKb(f.user(), time.Now())
+	if status == nil || status.Success || status.Counts.Failed != 1 {
+		t.Fatalf("exportKb status = %+v, want the doc failed", status)
+	}
+	list, err := loadFailedDocs(filepath.Join(out, failedDocsFile))
+	if err != nil {
+		t.Fatalf("loadFailedDocs: %v", err)
+	}
+	if len(list) != 1 || list[0].DocGuid != "doc1" {
+		t.Errorf("failed.json = %+v, want doc1", list)
+	}
+}
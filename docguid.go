@@ -0,0 +1,56 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"strings"
+	"sync/atomic"
+)
+
+var docGuids = flag.String("docGuids", "", "export the docs by docGuid, like guid1,guid2, into their folders without listing them")
+
+// docByGuid returns the doc of the guid by the doc info api, or a doc named after the guid
+// if its info is not available.
+func docByGuid(wizUser *WizUser, docGuid string) *Doc {
+	doc, err := kbClient(wizUser).DocInfo(docGuid)
+	if err != nil {
+		logErrorf("\tdoc info %s err: %v, named after the guid\n", docGuid, err)
+		return &Doc{DocGuid: docGuid, Title: docGuid}
+	}
+	if doc.DocGuid == "" {
+		doc.DocGuid = docGuid
+	}
+	if doc.Title == "" {
+		doc.Title = docGuid
+	}
+	return doc
+}
+
+// fetchDocGuids exports the docs of --docGuids one by one, each into the folder of its category.
+func fetchDocGuids(root string, wizUser *WizUser) {
+	var guids []string
+	for _, guid := range strings.Split(*docGuids, ",") {
+		if guid = strings.TrimSpace(guid); guid != "" {
+			guids = append(guids, guid)
+		}
+	}
+	atomic.AddInt64(&docsQueued, int64(len(guids)))
+	for _, guid := range guids {
+		if exportStopped() {
+			return
+		}
+		exportDocIn(root, wizUser, docByGuid(wizUser, guid))
+	}
+}
+
+// exportDocIn exports the doc into the folder of its category under root.
+func exportDocIn(root string, wizUser *WizUser, doc *Doc) {
+	parentPath := folderPath(root, doc.Category)
+	if err := os.MkdirAll(parentPath, 0755); err != nil {
+		stepErr("MkdirAll "+parentPath, err)
+		return
+	}
+	logInfof("Doc info: %s\n\tdocGuid: %s\n\ttitle: %s\n\tattachmentCount:%v\n",
+		progress(), doc.DocGuid, doc.Title, doc.AttachmentCount)
+	exportDoc(parentPath, wizUser, doc)
+}
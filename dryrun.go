@@ -0,0 +1,45 @@
+package main
+
+import (
+	"flag"
+	"path/filepath"
+)
+
+var (
+	dryRun = flag.Bool("dryRun", false, "list the docs to export with their files and resource counts, without writing or downloading anything")
+
+	dryRunFolders, dryRunDocs, dryRunRes int
+)
+
+// previewFolder prints the docs of the folder as they would be exported. The docs are fetched
+// to count their resources, nothing is written.
+func previewFolder(root string, wizUser *WizUser, folder string) error {
+	list, err := listFolderDocs(wizUser, folder)
+	if err != nil {
+		return err
+	}
+	docs := filterDocs(list)
+	parentPath := folderPath(root, folder)
+	logInfof("Dry run:\n\tfolder: %s\n\tdocs: %v\n", folder, len(docs))
+	dryRunFolders++
+	for _, doc := range docs {
+		if exportStopped() {
+			break
+		}
+		file := claimDocFile(filepath.Join(parentPath, docFileName(doc)), doc.DocGuid)
+		exported, err := kbClient(wizUser).ExportDoc(doc)
+		if err != nil {
+			logInfof("\t%s -> %s, resources: unknown, err: %v\n", doc.Title, file, err)
+			continue
+		}
+		res := len(exported.Resources) + doc.AttachmentCount
+		logInfof("\t%s -> %s, resources: %v\n", doc.Title, file, res)
+		dryRunDocs++
+		dryRunRes += res
+	}
+	return nil
+}
+
+func printDryRun() {
+	logResultf("Dry run info:\n\tfolders: %v\n\tdocs: %v\n\tresources: %v\n", dryRunFolders, dryRunDocs, dryRunRes)
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/GalaIO/wiz_export/wiz"
+)
+
+var (
+	emptyMu sync.Mutex
+	// folder -> why nothing was exported from it
+	emptyFolders = make(map[string]string)
+	// kbGuid -> folders of the kb, to tell a wrong folder from an empty one
+	kbFolders = make(map[string]map[string]bool)
+)
+
+// checkFolderDocs warns about a folder exported empty and why, the folders are listed again
+// in the summary: wiz refused the listing, the filters skipped all its docs, no such folder or no docs.
+func checkFolderDocs(wizUser *WizUser, folder string, listed, kept int, err error) {
+	var reason string
+	var ce *wiz.CodeError
+	switch {
+	case errors.As(err, &ce):
+		reason = fmt.Sprintf("wiz returned code %v: %s, check the permission of the kb", ce.Code, ce.Message)
+	case err != nil || kept > 0:
+		// network errors are retried
+		emptyMu.Lock()
+		delete(emptyFolders, folder)
+		emptyMu.Unlock()
+		return
+	case listed > 0:
+		reason = fmt.Sprintf("all the %v docs skipped by the filters", listed)
+	case !knownFolder(wizUser, folder):
+		reason = "no such folder in the kb, check the path, like /日记/ starting and ending with /"
+	default:
+		reason = "no docs in the folder"
+	}
+	logResultf("\tempty folder: %s, %s\n", folder, reason)
+	emptyMu.Lock()
+	emptyFolders[folder] = reason
+	emptyMu.Unlock()
+}
+
+// knownFolder reports whether the folder is one of the kb, true if the folders can't be listed.
+func knownFolder(wizUser *WizUser, folder string) bool {
+	emptyMu.Lock()
+	folders, ok := kbFolders[wizUser.KbGuid]
+	emptyMu.Unlock()
+	if !ok {
+		categories, err := fetchCategories(wizUser)
+		if err != nil {
+			return true
+		}
+		folders = make(map[string]bool, len(categories))
+		for _, category := range categories {
+			folders[category] = true
+		}
+		emptyMu.Lock()
+		kbFolders[wizUser.KbGuid] = folders
+		emptyMu.Unlock()
+	}
+	return folders[folder]
+}
+
+func printEmptyFolders() {
+	emptyMu.Lock()
+	defer emptyMu.Unlock()
+	if len(emptyFolders) == 0 {
+		return
+	}
+	folders := make([]string, 0, len(emptyFolders))
+	for folder := range emptyFolders {
+		folders = append(folders, folder)
+	}
+	sort.Strings(folders)
+	logResultf("Empty folders:\n\tcount: %v\n", len(folders))
+	for _, folder := range folders {
+		logResultf("\t%s: %s\n", folder, emptyFolders[folder])
+	}
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync/atomic"
+)
+
+var onExisting = flag.String("onExisting", "overwrite", "when the doc files exist: skip them, overwrite them, or clean the files of the earlier export in its manifest "+
+	"before the export, the other files are kept")
+
+func checkOnExisting() error {
+	switch *onExisting {
+	case "skip", "overwrite", "clean":
+		return nil
+	}
+	return fmt.Errorf("unknown onExisting: %s, one of skip, overwrite, clean", *onExisting)
+}
+
+// skipExisting skips the docs whose file is there already with --onExisting skip.
+func skipExisting(root string, doc *Doc) bool {
+	if *onExisting != "skip" {
+		return false
+	}
+	docPath := claimDocFile(filepath.Join(root, docFileName(doc)), doc.DocGuid)
+	if *format == "html" {
+		docPath = strings.TrimSuffix(docPath, ".md") + ".html"
+	}
+	if _, err := os.Stat(docPath); err != nil {
+		// a doc split by headings is a folder named after its file
+		if _, err := os.Stat(strings.TrimSuffix(docPath, ".md")); err != nil {
+			return false
+		}
+	}
+	logInfof("\tskip existing: %s %s\n", doc.DocGuid, docPath)
+	atomic.AddInt64(&docsSkipped, 1)
+	return true
+}
+
+// the files written by the export besides the docs and resources, in the kb folder
+var exportArtifacts = []string{
+	manifestFile, diffReportFile, assetsMapFile, failedResFile, failedResScriptFile, failedDocsFile,
+	statusFile, exportInfoFile, indexJSONFile, indexMdFile, tagIndexFile, todosFile, mkdocsFile,
+	ankiFile, ankiMedia, stateFile,
+}
+
+// the files written by the export in the folders of the docs
+var folderArtifacts = []string{folderIndexFile, folderManifestFile, tagsFile, "_collection.md"}
+
+// cleanRoot removes the files of the earlier export in the kb folder with --onExisting clean:
+// the docs and resources of its manifest and the files the export writes. The other files, like
+// .git or the notes of the user, are kept. A folder with files but no manifest is refused, and
+// so are / and the home.
+func cleanRoot(root string) error {
+	abs, err := filepath.Abs(root)
+	if err != nil {
+		return WrapErr("abs output", err)
+	}
+	home, _ := os.UserHomeDir()
+	if abs == filepath.Dir(abs) || abs == home {
+		return fmt.Errorf("refuse to clean %s", abs)
+	}
+	entries, err := ioutil.ReadDir(root)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return WrapErr("read output", err)
+	}
+	if len(entries) == 0 {
+		return nil
+	}
+	if _, err := os.Stat(filepath.Join(root, manifestFile)); err != nil {
+		return errors.New("refuse to clean " + abs + ", no " + manifestFile + " of an earlier export in it")
+	}
+	m, err := loadManifest(filepath.Join(root, manifestFile))
+	if err != nil {
+		return err
+	}
+
+	files := append([]string(nil), exportArtifacts...)
+	dirs := make(map[string]bool)
+	for _, entry := range m.Docs {
+		docFiles := append([]string{entry.Path}, entry.Parts...)
+		if strings.HasSuffix(entry.Path, ".md") {
+			// the html copy of --format both
+			docFiles = append(docFiles, strings.TrimSuffix(entry.Path, ".md")+".html")
+		}
+		docFiles = append(docFiles, entry.Resources...)
+		for _, thumb := range entry.Thumbs {
+			docFiles = append(docFiles, thumb)
+		}
+		for _, file := range docFiles {
+			// the manifest is relative to the output, the kb folder in it with several kbs
+			if file = cleanTarget(root, file); file == "" {
+				continue
+			}
+			files = append(files, file)
+			for dir := filepath.Dir(file); dir != "." && !dirs[dir]; dir = filepath.Dir(dir) {
+				dirs[dir] = true
+			}
+		}
+	}
+	for dir := range dirs {
+		for _, name := range folderArtifacts {
+			files = append(files, filepath.Join(dir, name))
+		}
+	}
+	for _, name := range folderArtifacts {
+		files = append(files, name)
+	}
+
+	removed := 0
+	for _, file := range files {
+		full := filepath.Join(root, file)
+		if _, err := os.Lstat(full); err != nil {
+			continue
+		}
+		if err := os.RemoveAll(full); err != nil {
+			return WrapErr("clean output", err)
+		}
+		removed++
+	}
+	// the folders left empty, the deepest first
+	sorted := make([]string, 0, len(dirs))
+	for dir := range dirs {
+		sorted = append(sorted, dir)
+	}
+	sort.Slice(sorted, func(i, j int) bool { return len(sorted[i]) > len(sorted[j]) })
+	for _, dir := range sorted {
+		os.Remove(filepath.Join(root, dir))
+	}
+	logInfof("Clean info:\n\tfolder: %s\n\tremoved: %v\n", abs, removed)
+	return nil
+}
+
+// cleanTarget returns the file of the manifest path relative to the kb folder root, empty for
+// the paths out of it.
+func cleanTarget(root, file string) string {
+	rel, err := filepath.Rel(root, localPath(exportRoot, file))
+	if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return ""
+	}
+	return rel
+}
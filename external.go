@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"flag"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+var downloadExternal = flag.Bool("downloadExternalImages", false, "download the http(s) images of the docs into their resource folder too, the images failed to download keep their links")
+
+// the external images of the markdown, like ![](https://img.example.com/a.png)
+var externalImageRe = regexp.MustCompile(`(!\[[^\]]*\]\()(https?://[^)\s]+)\)`)
+
+// externalName returns the local file of the external image, named by the hash of its url.
+func externalName(imgURL string) string {
+	sum := sha1.Sum([]byte(imgURL))
+	ext := ""
+	if u, err := url.Parse(imgURL); err == nil {
+		ext = strings.ToLower(path.Ext(u.Path))
+	}
+	if len(ext) > 6 {
+		ext = ""
+	}
+	return "ext-" + hex.EncodeToString(sum[:8]) + ext
+}
+
+// localizeExternalImages downloads the external images of the markdown into dir, pointing the
+// downloaded ones to index_files/ like the wiz resources, and returns their file names.
+func localizeExternalImages(ctx context.Context, dir, markdown string) (string, []string) {
+	var urls []string
+	seen := make(map[string]bool)
+	for _, m := range externalImageRe.FindAllStringSubmatch(markdown, -1) {
+		if !seen[m[2]] {
+			seen[m[2]] = true
+			urls = append(urls, m[2])
+		}
+	}
+	if len(urls) == 0 {
+		return markdown, nil
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		logErrorf("\tMkdirAll %s err: %v\n", dir, err)
+		return markdown, nil
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	downloaded := make(map[string]string)
+	resSlots := make(chan struct{}, resWorkerCount())
+	for _, imgURL := range urls {
+		imgURL := imgURL
+		name := externalName(imgURL)
+		logDebugf("\texternal image: %s -> %s\n", imgURL, name)
+		// the doc holds its task while the images download, they take no tasks of their own
+		resSlots <- struct{}{}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-resSlots }()
+			ext, err := fetchExternalImage(ctx, filepath.Join(dir, name), imgURL)
+			if err != nil {
+				logErrorf("\texternal image err: %v %v, keep the link\n", imgURL, err)
+				return
+			}
+			mu.Lock()
+			downloaded[imgURL] = name + ext
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	var names []string
+	for _, imgURL := range urls {
+		if name, ok := downloaded[imgURL]; ok {
+			names = append(names, name)
+		}
+	}
+	markdown = externalImageRe.ReplaceAllStringFunc(markdown, func(m string) string {
+		sub := externalImageRe.FindStringSubmatch(m)
+		if name, ok := downloaded[sub[2]]; ok {
+			return sub[1] + "index_files/" + name + ")"
+		}
+		return m
+	})
+	return markdown, names
+}
+
+// fetchExternalImage downloads the image without the wiz token, existing files are kept.
+// It returns the extension added by the content type to the file without one.
+func fetchExternalImage(ctx context.Context, resPath, imgURL string) (string, error) {
+	noExt := filepath.Ext(resPath) == ""
+	if noExt {
+		if ext, ok := existingExt(resPath); ok {
+			return ext, nil
+		}
+	}
+	if _, err := os.Stat(resPath); err == nil {
+		return "", nil
+	}
+	data, err := FetchRes(ctx, imgURL, "")
+	if err != nil {
+		return "", err
+	}
+	ext := ""
+	if noExt {
+		ext = detectExt(data)
+	}
+	recordAsset(resPath+ext, imgURL)
+	if err := writeFile(resPath+ext, data); err != nil {
+		return "", WrapErr("WriteFile external image", err)
+	}
+	resDownloaded("", resPath+ext)
+	return ext, nil
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+)
+
+const failedDocsFile = "failed.json"
+
+var (
+	retryFailed = flag.String("retryFailed", "", "export again only the docs and folders listed in a failed.json, the exported ones are removed from it")
+
+	failedMu sync.Mutex
+	// prevFailed are the docs and folders of the last failed.json and --retryFailed, kept in the
+	// new failed.json until they are exported again
+	prevFailed []*FailedDoc
+	// reachedFailed are the docs and folders exported again in this run, by failedKey, the docs
+	// failing again are listed by their tasks
+	reachedFailed = make(map[string]bool)
+)
+
+// FailedDoc is a doc or a folder failed in all the retries, listed in failed.json.
+type FailedDoc struct {
+	// DocGuid is empty for a folder failed to list
+	DocGuid string `json:"docGuid,omitempty"`
+	Title   string `json:"title,omitempty"`
+	Folder  string `json:"folder"`
+	Err     string `json:"err"`
+}
+
+// failedKey identifies a doc or a folder of failed.json.
+func failedKey(f *FailedDoc) string {
+	if f.DocGuid != "" {
+		return f.DocGuid
+	}
+	return "folder " + f.Folder
+}
+
+// loadFailedDocs reads the failed.json of the last run, its entries not reached in this run are
+// carried to the new one.
+func loadFailedDocs(file string) ([]*FailedDoc, error) {
+	bs, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var list []*FailedDoc
+	if err := json.Unmarshal(bs, &list); err != nil {
+		return nil, WrapErr("Unmarshal failed docs", err)
+	}
+	return list, nil
+}
+
+func addPrevFailed(list []*FailedDoc) {
+	failedMu.Lock()
+	defer failedMu.Unlock()
+	prevFailed = append(prevFailed, list...)
+}
+
+// reachFailed marks the doc or folder exported again, to drop it from failed.json.
+func reachFailed(f *FailedDoc) {
+	failedMu.Lock()
+	defer failedMu.Unlock()
+	reachedFailed[failedKey(f)] = true
+}
+
+// carriedFailed returns the entries of the last failed.json not exported again in this run, like
+// in an interrupted run or out of its folders, without the docs gone from their listed folders.
+func carriedFailed() []*FailedDoc {
+	failedMu.Lock()
+	defer failedMu.Unlock()
+	manifestMu.Lock()
+	defer manifestMu.Unlock()
+	var carried []*FailedDoc
+	for _, f := range prevFailed {
+		if reachedFailed[failedKey(f)] || f.DocGuid != "" && listedFolders[f.Folder] && !listedDocs[f.DocGuid] {
+			continue
+		}
+		carried = append(carried, f)
+	}
+	return carried
+}
+
+// resetFailed clears the failed docs of the kb exported before.
+func resetFailed() {
+	failedMu.Lock()
+	defer failedMu.Unlock()
+	prevFailed = nil
+	reachedFailed = make(map[string]bool)
+}
+
+// writeFailedDocs writes the failed docs and folders as failed.json for --retryFailed, with the
+// entries of the last one not exported again, it is removed once nothing fails.
+func writeFailedDocs(root string, failed []*retryTask) error {
+	list := []*FailedDoc{}
+	listed := make(map[string]bool)
+	for _, task := range failed {
+		if task.doc != nil {
+			f := *task.doc
+			f.Err = task.err.Error()
+			list = append(list, &f)
+			listed[failedKey(&f)] = true
+		}
+	}
+	for _, f := range carriedFailed() {
+		if !listed[failedKey(f)] {
+			listed[failedKey(f)] = true
+			list = append(list, f)
+		}
+	}
+	file := filepath.Join(root, failedDocsFile)
+	if len(list) == 0 {
+		os.Remove(file)
+		return nil
+	}
+	bs, err := json.MarshalIndent(list, "", "  ")
+	if err != nil {
+		return WrapErr("Marshal failed docs", err)
+	}
+	if err := atomicWriteFile(file, bs, 0644); err != nil {
+		return WrapErr("WriteFile failed docs", err)
+	}
+	logInfof("Failed doc info:\n\tcount: %v\n\tlist: %s, retry by --retryFailed\n", len(list), file)
+	return nil
+}
+
+// retryFailedDocs exports the docs and folders of the failed.json again, the docs failing again
+// are queued for retry and listed in the new failed.json.
+func retryFailedDocs(root string, wizUser *WizUser, file string) error {
+	list, err := loadFailedDocs(file)
+	if err != nil {
+		return WrapErr("read failed docs", err)
+	}
+	logInfof("Retry failed info:\n\tfile: %s\n\tcount: %v\n", file, len(list))
+	if *dryRun {
+		for _, f := range list {
+			logInfof("\t%s %s %s\n", f.Folder, f.DocGuid, f.Title)
+		}
+		return nil
+	}
+	addPrevFailed(list)
+	for _, f := range list {
+		// the docs of the folders are counted once listed
+		if f.DocGuid != "" {
+			atomic.AddInt64(&docsQueued, 1)
+		}
+	}
+	for _, f := range list {
+		if exportStopped() {
+			break
+		}
+		if f.DocGuid == "" {
+			folder := f.Folder
+			if err := fetchFolder(root, wizUser, folder); err != nil {
+				enqueueFolderRetry(root, wizUser, folder, err)
+			}
+			continue
+		}
+		doc := docByGuid(wizUser, f.DocGuid)
+		if doc.Title == f.DocGuid && f.Title != "" {
+			doc.Title = f.Title
+		}
+		if doc.Category == "" {
+			doc.Category = f.Folder
+		}
+		exportDocIn(root, wizUser, doc)
+	}
+	return nil
+}
+
+func failedDoc(doc *Doc) *FailedDoc {
+	return &FailedDoc{DocGuid: doc.DocGuid, Title: doc.Title, Folder: doc.Category}
+}
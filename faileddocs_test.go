@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestRetryFailedInterrupted(t *testing.T) {
+	prevCtx, prevCancel := exportCtx, cancelExport
+	exportCtx, cancelExport = context.WithCancel(context.Background())
+	t.Cleanup(func() { exportCtx, cancelExport = prevCtx, prevCancel })
+
+	f := newFakeWiz(t)
+	f.html["doc1"] = "<p>one</p>"
+	// the export is interrupted while doc2 is exported, doc3 is never reached
+	f.onRequest = func(r *http.Request) {
+		if r.URL.Path == "/ks/note/view/kb1/doc2" {
+			cancelExport()
+		}
+	}
+	out := setupExport(t, f, nil)
+	failedFile := filepath.Join(out, failedDocsFile)
+	list := []*FailedDoc{
+		{DocGuid: "doc1", Title: "One", Folder: "/a/", Err: "timeout"},
+		{DocGuid: "doc2", Title: "Two", Folder: "/a/", Err: "timeout"},
+		{DocGuid: "doc3", Title: "Three", Folder: "/a/", Err: "timeout"},
+	}
+	bs, _ := json.Marshal(list)
+	if err := ioutil.WriteFile(failedFile, bs, 0644); err != nil {
+		t.Fatal(err)
+	}
+	setFlags(t, map[string]string{"retryFailed": failedFile})
+
+	exportKb(f.user(), time.Now())
+	if n := f.count("/ks/note/view/kb1/doc3"); n != 0 {
+		t.Fatalf("doc3 exported after the interrupt, %d requests", n)
+	}
+	left, err := loadFailedDocs(failedFile)
+	if err != nil {
+		t.Fatalf("loadFailedDocs: %v", err)
+	}
+	var guids []string
+	for _, f := range left {
+		guids = append(guids, f.DocGuid)
+	}
+	if want := []string{"doc2", "doc3"}; !reflect.DeepEqual(guids, want) {
+		t.Errorf("failed.json docs = %v, want %v", guids, want)
+	}
+}
+
+func TestWriteFailedDocs(t *testing.T) {
+	root := t.TempDir()
+	resetKb()
+	t.Cleanup(resetKb)
+	addPrevFailed([]*FailedDoc{
+		{DocGuid: "done", Folder: "/a/"},
+		{DocGuid: "again", Folder: "/a/", Err: "old"},
+		{DocGuid: "gone", Folder: "/a/"},
+		{DocGuid: "other", Folder: "/b/"},
+		{Folder: "/c/"},
+		{Folder: "/d/"},
+	})
+	recordListed("/a/", []*Doc{{DocGuid: "done"}, {DocGuid: "again"}})
+	reachFailed(&FailedDoc{DocGuid: "done"})
+	reachFailed(&FailedDoc{DocGuid: "again"})
+	reachFailed(&FailedDoc{Folder: "/c/"})
+	failed := []*retryTask{{err: errInterrupted, doc: &FailedDoc{DocGuid: "again", Folder: "/a/"}}}
+
+	if err := writeFailedDocs(root, failed); err != nil {
+		t.Fatalf("writeFailedDocs: %v", err)
+	}
+	list, err := loadFailedDocs(filepath.Join(root, failedDocsFile))
+	if err != nil {
+		t.Fatalf("loadFailedDocs: %v", err)
+	}
+	want := []*FailedDoc{
+		{DocGuid: "again", Folder: "/a/", Err: errInterrupted.Error()},
+		{DocGuid: "other", Folder: "/b/"},
+		{Folder: "/d/"},
+	}
+	if !reflect.DeepEqual(list, want) {
+		got, _ := json.Marshal(list)
+		t.Errorf("failed.json = %s", got)
+	}
+}
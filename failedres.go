@@ -0,0 +1,120 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	failedResFile       = "failed_res.json"
+	failedResScriptFile = "failed_res.sh"
+)
+
+var retryRes = flag.String("retry-res", "", "download again the resources listed in a failed_res.json, then exit")
+
+type FailedRes struct {
+	URL string `json:"url"`
+	// Path is relative to the output, slash separated
+	Path string `json:"path"`
+}
+
+// resTask downloads a resource, it is listed in failed_res.json if its retries fail.
+func resTask(resPath string, wizUser *WizUser, doc *Doc, fname string) *retryTask {
+	return &retryTask{
+		name: "res " + doc.DocGuid + "/" + fname,
+		run: func(ctx context.Context) error {
+			return fetchRes(ctx, resPath, wizUser, doc, fname)
+		},
+		res: &FailedRes{URL: resourceURL(wizUser, doc, fname), Path: relPath(resPath)},
+	}
+}
+
+// writeFailedRes writes the failed resources as failed_res.json for --retry-res, and as a
+// curl script reading the token from WIZ_TOKEN. Both are removed once nothing fails.
+func writeFailedRes(root string, failed []*retryTask) error {
+	list := []*FailedRes{}
+	for _, task := range failed {
+		if task.res != nil {
+			list = append(list, task.res)
+		}
+	}
+	jsonFile, scriptFile := filepath.Join(root, failedResFile), filepath.Join(root, failedResScriptFile)
+	if len(list) == 0 {
+		os.Remove(jsonFile)
+		os.Remove(scriptFile)
+		return nil
+	}
+	bs, err := json.MarshalIndent(list, "", "  ")
+	if err != nil {
+		return WrapErr("Marshal failed res", err)
+	}
+	if err := atomicWriteFile(jsonFile, bs, 0644); err != nil {
+		return WrapErr("WriteFile failed res", err)
+	}
+
+	var script bytes.Buffer
+	script.WriteString("#!/bin/sh\n# downloads the failed resources again, run in the output folder: WIZ_TOKEN=xx sh " + failedResScriptFile + "\n")
+	for _, res := range list {
+		fmt.Fprintf(&script, "curl -fsS -H \"X-Wiz-Token: $WIZ_TOKEN\" -o %s %s\n", shellQuote(res.Path), shellQuote(res.URL))
+	}
+	if err := atomicWriteFile(scriptFile, script.Bytes(), 0755); err != nil {
+		return WrapErr("WriteFile failed res script", err)
+	}
+	logInfof("Failed resource info:\n\tcount: %v\n\tlist: %s\n\tscript: %s\n", len(list), jsonFile, scriptFile)
+	return nil
+}
+
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// retryFailedRes downloads the resources of the list again, keeping the ones still failing in it.
+// A dry run only lists them.
+func retryFailedRes(root string, wizUser *WizUser, file string) error {
+	bs, err := ioutil.ReadFile(file)
+	if err != nil {
+		return WrapErr("read failed res", err)
+	}
+	var list []*FailedRes
+	if err := json.Unmarshal(bs, &list); err != nil {
+		return WrapErr("Unmarshal failed res", err)
+	}
+	if *dryRun {
+		// list what would be downloaded, writing nothing
+		logInfof("Dry run:\n\tretry resources: %v\n", len(list))
+		for _, res := range list {
+			logInfof("\t%s -> %s\n", res.URL, res.Path)
+		}
+		return nil
+	}
+	var failed []*FailedRes
+	for _, res := range list {
+		data, err := FetchRes(exportCtx, res.URL, wizUser.CurrentToken())
+		if err == nil {
+			resPath := filepath.Join(root, filepath.FromSlash(res.Path))
+			if err = os.MkdirAll(filepath.Dir(resPath), 0755); err == nil {
+				err = writeFile(resPath, data)
+			}
+		}
+		if err != nil {
+			logErrorf("\tretry res %s err: %v\n", res.Path, err)
+			failed = append(failed, res)
+		}
+	}
+	logInfof("Retry resource info:\n\tcount: %v\n\tfailed: %v\n", len(list), len(failed))
+	if len(failed) == 0 {
+		return os.Remove(file)
+	}
+	bs, err = json.MarshalIndent(failed, "", "  ")
+	if err != nil {
+		return WrapErr("Marshal failed res", err)
+	}
+	return atomicWriteFile(file, bs, 0644)
+}
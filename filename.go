@@ -0,0 +1,163 @@
+package main
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// maxFileName is the longest file name in bytes of most file systems.
+const maxFileName = 255
+
+// the file names windows reserves for devices
+var reservedNames = map[string]bool{
+	"CON": true, "PRN": true, "AUX": true, "NUL": true,
+	"COM1": true, "COM2": true, "COM3": true, "COM4": true, "COM5": true, "COM6": true, "COM7": true, "COM8": true, "COM9": true,
+	"LPT1": true, "LPT2": true, "LPT3": true, "LPT4": true, "LPT5": true, "LPT6": true, "LPT7": true, "LPT8": true, "LPT9": true,
+}
+
+var (
+	docFilesMu sync.Mutex
+	// doc files taken in this run, relative to the output -> docGuid
+	docFiles = make(map[string]string)
+)
+
+// sanitizeFileName replaces the characters not allowed in file names on windows and macOS,
+// like the / of "2024/03 复盘", and truncates long names keeping the extension.
+func sanitizeFileName(name string) string {
+	name = strings.Map(func(r rune) rune {
+		if strings.ContainsRune(`/\:*?"<>|`, r) || r < 0x20 || r == 0x7f {
+			return '_'
+		}
+		return r
+	}, strings.TrimSpace(name))
+	// windows drops the trailing dots and spaces
+	name = strings.TrimRight(name, ". ")
+	if name == "" {
+		return "_"
+	}
+	// and reserves the device names, with any extension too, like CON.md
+	if stem := strings.SplitN(name, ".", 2)[0]; reservedNames[strings.ToUpper(strings.TrimRight(stem, " "))] {
+		name = stem + "_" + name[len(stem):]
+	}
+	return truncateFileName(name, maxFileName)
+}
+
+// truncateFileName cuts the name to max bytes on a rune boundary, the extension is kept.
+func truncateFileName(name string, max int) string {
+	if len(name) <= max {
+		return name
+	}
+	ext := path.Ext(name)
+	if len(ext) >= max/2 {
+		ext = ""
+	}
+	base := strings.TrimSuffix(name, ext)
+	n := max - len(ext)
+	for n > 0 && !utf8.RuneStart(base[n]) {
+		n--
+	}
+	return base[:n] + ext
+}
+
+// docNameMax is the longest doc file name, the html copy of --format html or both is 2 bytes longer.
+func docNameMax() int {
+	if *format == "html" || *format == "both" {
+		return maxFileName - len(".html") + len(".md")
+	}
+	return maxFileName
+}
+
+// claimDocFile returns the file for the doc, suffixed like title-2.md if another doc of this run
+// took it. A doc exported again, like when retried, gets its file back, and so does a doc exported
+// last time while no other doc took its file.
+func claimDocFile(file, docGuid string) string {
+	docFilesMu.Lock()
+	defer docFilesMu.Unlock()
+	if i := candidateIndex(file, prevDocFile(docGuid)); i > 0 {
+		prev := suffixedFile(file, i)
+		if owner, ok := docFiles[relPath(prev)]; !ok || owner == docGuid {
+			docFiles[relPath(prev)] = docGuid
+			return prev
+		}
+	}
+	for i := 1; ; i++ {
+		candidate := suffixedFile(file, i)
+		if owner, ok := docFiles[relPath(candidate)]; !ok || owner == docGuid {
+			docFiles[relPath(candidate)] = docGuid
+			return candidate
+		}
+	}
+}
+
+// suffixedFile returns the i-th file name for a doc, like title-2.md for 2, the name is cut to
+// leave room for the suffix.
+func suffixedFile(file string, i int) string {
+	if i < 2 {
+		return file
+	}
+	dir, name := filepath.Split(file)
+	ext := filepath.Ext(name)
+	suffix := fmt.Sprintf("-%d", i)
+	return dir + truncateFileName(strings.TrimSuffix(name, ext), docNameMax()-len(suffix)-len(ext)) + suffix + ext
+}
+
+// candidateIndex returns the i of suffixedFile(file, i) that is the file rel relative to the
+// output, 0 if none.
+func candidateIndex(file, rel string) int {
+	if rel == "" {
+		return 0
+	}
+	if relPath(file) == rel {
+		return 1
+	}
+	stem := strings.TrimSuffix(path.Base(rel), path.Ext(rel))
+	if k := strings.LastIndex(stem, "-"); k >= 0 {
+		if i, err := strconv.Atoi(stem[k+1:]); err == nil && i >= 2 && relPath(suffixedFile(file, i)) == rel {
+			return i
+		}
+	}
+	return 0
+}
+
+// prevDocFile returns the markdown file of the doc in the last manifest relative to the output,
+// empty if it was not exported.
+func prevDocFile(docGuid string) string {
+	manifestMu.Lock()
+	prev, ok := prevDocs[docGuid]
+	manifestMu.Unlock()
+	switch {
+	case !ok:
+		return ""
+	case len(prev.Parts) > 0:
+		// the folder of a split doc is named after its file
+		return prev.Path + ".md"
+	case strings.HasSuffix(prev.Path, ".html"):
+		return strings.TrimSuffix(prev.Path, ".html") + ".md"
+	}
+	return prev.Path
+}
+
+// claimFolderFiles claims the files of the folder docs in list order before they are exported
+// by the workers, the docs of the last export first, so that the doc getting the plain name of
+// a shared title keeps it across runs.
+func claimFolderFiles(root string, docs []*Doc) {
+	for _, pass := range []bool{true, false} {
+		for _, doc := range docs {
+			if (prevDocFile(doc.DocGuid) != "") == pass {
+				claimDocFile(filepath.Join(root, docFileName(doc)), doc.DocGuid)
+			}
+		}
+	}
+}
+
+// reserveDocFile keeps the file of a doc not exported again, relative to the output, from other docs.
+func reserveDocFile(rel, docGuid string) {
+	docFilesMu.Lock()
+	defer docFilesMu.Unlock()
+	docFiles[rel] = docGuid
+}
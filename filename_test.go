@@ -0,0 +1,111 @@
+package main
+
+import (
+	"path"
+	"path/filepath"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestSanitizeFileName(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"plain", "Doc One", "Doc One"},
+		{"slash", "2024/03 复盘", "2024_03 复盘"},
+		{"reserved chars", `a\b:c*d?e"f<g>h|i`, "a_b_c_d_e_f_g_h_i"},
+		{"control chars", "a\tb\x7f", "a_b_"},
+		{"trimmed", "  title. . ", "title"},
+		{"empty", " .. ", "_"},
+		{"device", "con", "con_"},
+		{"device with extension", "CON.md", "CON_.md"},
+		{"device prefix", "CONSOLE.md", "CONSOLE.md"},
+		{"long", strings.Repeat("a", 300) + ".md", strings.Repeat("a", maxFileName-3) + ".md"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := sanitizeFileName(tt.in); got != tt.want {
+				t.Errorf("sanitizeFileName(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTruncateFileName(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		max  int
+		want string
+	}{
+		{"short", "abc.md", 10, "abc.md"},
+		{"extension kept", "abcdefgh.md", 8, "abcde.md"},
+		{"rune boundary", "一二三.md", 10, "一二.md"},
+		{"long extension dropped", "a.verylongext", 8, "a.verylo"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := truncateFileName(tt.in, tt.max)
+			if got != tt.want {
+				t.Errorf("truncateFileName(%q, %d) = %q, want %q", tt.in, tt.max, got, tt.want)
+			}
+			if len(got) > tt.max || !utf8.ValidString(got) {
+				t.Errorf("truncateFileName(%q, %d) = %q, over max or not utf8", tt.in, tt.max, got)
+			}
+		})
+	}
+}
+
+func TestClaimDocFile(t *testing.T) {
+	resetKb()
+	t.Cleanup(resetKb)
+	prevRoot := exportRoot
+	exportRoot = t.TempDir()
+	t.Cleanup(func() { exportRoot = prevRoot })
+	setPrevManifest(&Manifest{Docs: []*ManifestEntry{{DocGuid: "old", Path: "a/U-3.md"}}})
+
+	claims := []struct {
+		file, docGuid, want string
+	}{
+		{"a/T.md", "doc1", "a/T.md"},
+		{"a/T.md", "doc2", "a/T-2.md"},
+		{"a/T.md", "doc1", "a/T.md"},
+		{"a/T.md", "doc3", "a/T-3.md"},
+		{"a/T.md", "doc2", "a/T-2.md"},
+		// the doc of the last export gets its suffixed file back
+		{"a/U.md", "new", "a/U.md"},
+		{"a/U.md", "old", "a/U-3.md"},
+		{"a/U.md", "doc4", "a/U-2.md"},
+	}
+	for _, c := range claims {
+		if got := relPath(claimDocFile(filepath.Join(exportRoot, c.file), c.docGuid)); got != c.want {
+			t.Errorf("claimDocFile(%s, %s) = %s, want %s", c.file, c.docGuid, got, c.want)
+		}
+	}
+}
+
+func TestSuffixedFile(t *testing.T) {
+	long := strings.Repeat("长", 100) + ".md"
+	tests := []struct {
+		file string
+		i    int
+		want string
+	}{
+		{"a/T.md", 1, "a/T.md"},
+		{"a/T.md", 2, "a/T-2.md"},
+		{"a/T.md", 12, "a/T-12.md"},
+		{"a/" + long, 2, "a/" + strings.Repeat("长", 83) + "-2.md"},
+	}
+	for _, tt := range tests {
+		got := suffixedFile(tt.file, tt.i)
+		if got != tt.want {
+			t.Errorf("suffixedFile(%q, %d) = %q, want %q", tt.file, tt.i, got, tt.want)
+		}
+		if name := path.Base(got); len(name) > maxFileName {
+			t.Errorf("suffixedFile(%q, %d) name is %d bytes, over %d", tt.file, tt.i, len(name), maxFileName)
+		}
+	}
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	skipTypes     = flag.String("skip-types", "", "skip docs of types, like encrypted,collab or any raw wiz doc type")
+	markdownOnly  = flag.Bool("markdown-only", false, "export only the wiz markdown notes, taking their markdown as is")
+	accessedSince = flag.String("accessed-since", "", "export only the docs accessed since the date or the duration ago, like 2024-01-01, 720h or 90d")
+	since         = flag.String("since", "", "export only the docs created since the date, like 2024-01-01, or the duration ago, like 90d")
+	titleMatch    = flag.String("titleMatch", "", "export only the docs whose title matches the regexp, or contains the text")
+	keyword       = flag.String("keyword", "", "export only the docs with the keywords, comma separated, all of them")
+	until         = flag.String("until", "", "export only the docs created until the date, like 2024-03-31, the day included")
+
+	// docs accessed before are skipped, zero means no limit
+	accessedAfter time.Time
+	// docs created out of [createdAfter, createdBefore) are skipped, zero means no limit
+	createdAfter  time.Time
+	createdBefore time.Time
+	titleRe       *regexp.Regexp
+	docsSkipped   int64
+)
+
+// parseTitleMatch compiles --titleMatch.
+func parseTitleMatch() error {
+	if *titleMatch == "" {
+		return nil
+	}
+	re, err := regexp.Compile(*titleMatch)
+	if err != nil {
+		return fmt.Errorf("invalid --titleMatch regexp %q, escape the special characters like \\( to match as text, err: %w", *titleMatch, err)
+	}
+	titleRe = re
+	return nil
+}
+
+// matchDoc reports whether the doc matches --titleMatch and has all the --keyword keywords.
+func matchDoc(doc *Doc) bool {
+	if titleRe != nil && !titleRe.MatchString(doc.Title) {
+		return false
+	}
+	tags := make(map[string]bool)
+	for _, tag := range parseKeywords(doc.Keywords) {
+		tags[strings.ToLower(tag)] = true
+	}
+	for _, want := range parseKeywords(*keyword) {
+		if !tags[strings.ToLower(want)] {
+			return false
+		}
+	}
+	return true
+}
+
+// parseCreatedRange parses --since and --until in the local time zone.
+func parseCreatedRange() error {
+	if *since != "" {
+		t, err := parseSince(*since)
+		if err != nil {
+			return WrapErr("parse since", err)
+		}
+		createdAfter = t
+	}
+	if *until != "" {
+		t, err := time.ParseInLocation("2006-01-02", strings.TrimSpace(*until), time.Local)
+		if err != nil {
+			return WrapErr("parse until", err)
+		}
+		createdBefore = t.AddDate(0, 0, 1)
+	}
+	return nil
+}
+
+// createdInRange reports whether the doc was created in --since and --until.
+func createdInRange(doc *Doc) bool {
+	created := docTime(doc.Created)
+	if !createdAfter.IsZero() && created.Before(createdAfter) {
+		return false
+	}
+	return createdBefore.IsZero() || created.Before(createdBefore)
+}
+
+// docKinds returns the raw type of the doc and the derived kinds used by --skip-types.
+func docKinds(doc *Doc) []string {
+	kinds := []string{doc.Type}
+	if doc.Protected != 0 {
+		kinds = append(kinds, "encrypted")
+	}
+	if doc.Type == "collaboration" {
+		kinds = append(kinds, "collab")
+	}
+	return kinds
+}
+
+// filterDocs drops the docs not to be exported, printing why they are skipped.
+func filterDocs(docs []*Doc) []*Doc {
+	skip := make(map[string]bool)
+	for _, t := range strings.Split(*skipTypes, ",") {
+		if t = strings.TrimSpace(t); t != "" {
+			skip[t] = true
+		}
+	}
+	if len(skip) == 0 && !*markdownOnly && accessedAfter.IsZero() && createdAfter.IsZero() && createdBefore.IsZero() &&
+		titleRe == nil && *keyword == "" {
+		return docs
+	}
+
+	var kept []*Doc
+	skipped := 0
+	for _, doc := range docs {
+		if kind := matchKind(doc, skip); kind != "" {
+			logInfof("\tskip doc: %s %s, type: %s\n", doc.DocGuid, doc.Title, kind)
+			skipped++
+			continue
+		}
+		if !accessedAfter.IsZero() && (doc.Accessed == 0 || docTime(doc.Accessed).Before(accessedAfter)) {
+			logInfof("\tskip doc: %s %s, not accessed since %s\n", doc.DocGuid, doc.Title, accessedAfter.Format("2006-01-02"))
+			skipped++
+			continue
+		}
+		if !createdInRange(doc) {
+			logInfof("\tskip doc: %s %s, created %s out of the range\n", doc.DocGuid, doc.Title, docTime(doc.Created).Format("2006-01-02"))
+			skipped++
+			continue
+		}
+		if !matchDoc(doc) {
+			logInfof("\tskip doc: %s %s, not matching the title or keywords\n", doc.DocGuid, doc.Title)
+			skipped++
+			continue
+		}
+		if *markdownOnly && !isMarkdownDoc(doc) {
+			logInfof("\tskip doc: %s %s, not markdown, type: %s\n", doc.DocGuid, doc.Title, doc.Type)
+			skipped++
+			continue
+		}
+		kept = append(kept, doc)
+	}
+	atomic.AddInt64(&docsSkipped, int64(skipped))
+	logInfof("Skip info:\n\tcount: %v\n", skipped)
+	return kept
+}
+
+func matchKind(doc *Doc, kinds map[string]bool) string {
+	for _, kind := range docKinds(doc) {
+		if kinds[kind] {
+			return kind
+		}
+	}
+	return ""
+}
+
+// isMarkdownDoc reports whether the doc is a wiz markdown note, which keeps
+// its markdown source as the html text and has a title ending with .md.
+func isMarkdownDoc(doc *Doc) bool {
+	return doc.Type == "lite/markdown" || strings.HasSuffix(strings.ToLower(doc.Title), ".md")
+}
+
+// parseSince parses a date like 2024-01-01 or a duration ago like 720h or 90d.
+func parseSince(s string) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	if t, err := time.ParseInLocation("2006-01-02", s, time.Local); err == nil {
+		return t, nil
+	}
+	if strings.HasSuffix(s, "d") {
+		days, err := strconv.Atoi(strings.TrimSuffix(s, "d"))
+		if err != nil {
+			return time.Time{}, err
+		}
+		return time.Now().AddDate(0, 0, -days), nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Now().Add(-d), nil
+}
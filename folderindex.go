@@ -0,0 +1,89 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const folderIndexFile = "_index.md"
+
+var (
+	folderIndex = flag.Bool("folderIndex", false, "write _index.md in every exported folder, listing its docs in the wiz order")
+	breadcrumbs = flag.Bool("breadcrumbs", false, "link every doc back to its folder index and every folder index to its parent, implies --folderIndex")
+)
+
+// sortDocs orders the docs like wiz shows them: pinned first, then by the order weight, then by created.
+func sortDocs(docs []*Doc) []*Doc {
+	sorted := append([]*Doc(nil), docs...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		a, b := sorted[i], sorted[j]
+		if (a.Pinned != 0) != (b.Pinned != 0) {
+			return a.Pinned != 0
+		}
+		if a.Order != b.Order {
+			return a.Order > b.Order
+		}
+		return a.Created < b.Created
+	})
+	return sorted
+}
+
+// writeFolderIndex writes _index.md of the folder linking its docs in order, and its parent index if any.
+func writeFolderIndex(dir, folder, parent string, docs []*Doc) error {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "# %s\n\n", path.Base(folder))
+	if *breadcrumbs && parent != "" {
+		fmt.Fprintf(&buf, "[上级目录](<%s>)\n\n", parent)
+	}
+	for _, doc := range docs {
+		fmt.Fprintf(&buf, "- [%s](<%s>)\n", doc.Title, docLink(dir, dir, doc))
+	}
+	if err := atomicWriteFile(filepath.Join(dir, folderIndexFile), buf.Bytes(), 0644); err != nil {
+		return WrapErr("WriteFile folder index", err)
+	}
+	return nil
+}
+
+// docLink returns the file of the doc exported into docDir relative to dir: the file recorded in
+// the manifest, the first part of a split doc, and the claimed file of the docs not recorded.
+func docLink(dir, docDir string, doc *Doc) string {
+	manifestMu.Lock()
+	entry, ok := exportedDocs[doc.DocGuid]
+	if !ok {
+		// skipped as unchanged
+		entry, ok = prevDocs[doc.DocGuid]
+	}
+	manifestMu.Unlock()
+	file := ""
+	if ok {
+		file = localPath(exportRoot, entryLink(entry))
+	} else {
+		file = claimDocFile(filepath.Join(docDir, docFileName(doc)), doc.DocGuid)
+		if *format == "html" {
+			file = strings.TrimSuffix(file, ".md") + ".html"
+		}
+	}
+	rel, err := filepath.Rel(dir, file)
+	if err != nil {
+		return filepath.Base(file)
+	}
+	return filepath.ToSlash(rel)
+}
+
+// parentIndex returns the index of the parent folder, empty for the top folders.
+func parentIndex(folder string) string {
+	if !strings.Contains(strings.Trim(folder, "/"), "/") {
+		return ""
+	}
+	return "../" + folderIndexFile
+}
+
+// breadcrumb links the doc back to the index of its folder dir, depth is the folders between them.
+func breadcrumb(dir string, depth int) string {
+	return fmt.Sprintf("\n\n---\n\n[返回上级目录 %s](<%s%s>)\n", filepath.Base(dir), strings.Repeat("../", depth), folderIndexFile)
+}
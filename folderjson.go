@@ -0,0 +1,64 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"path/filepath"
+	"strings"
+)
+
+const folderManifestFile = "folder.json"
+
+var folderManifest = flag.Bool("folderManifest", false, "write folder.json into every exported folder with its docs and resources")
+
+type FolderManifest struct {
+	Folder    string           `json:"folder"`
+	Docs      []*ManifestEntry `json:"docs"`
+	Resources []string         `json:"resources"`
+}
+
+// writeFolderManifests writes folder.json of the folders exported in this run, the paths
+// in it are relative to the folder so that it can be moved and verified alone.
+func writeFolderManifests(docRoot string) error {
+	manifestMu.Lock()
+	folders := make([]string, 0, len(listedFolders))
+	for folder := range listedFolders {
+		folders = append(folders, folder)
+	}
+	manifestMu.Unlock()
+
+	docs := buildManifest().Docs
+	for _, folder := range folders {
+		dir := folderPath(docRoot, folder)
+		prefix := relPath(dir) + "/"
+		fm := &FolderManifest{Folder: folder, Resources: []string{}}
+		for _, entry := range docs {
+			if entry.Category != folder || !strings.HasPrefix(entry.Path, prefix) {
+				continue
+			}
+			local := *entry
+			local.Path = strings.TrimPrefix(entry.Path, prefix)
+			local.Parts = trimPaths(entry.Parts, prefix)
+			local.Resources = trimPaths(entry.Resources, prefix)
+			local.Thumbs = nil
+			fm.Docs = append(fm.Docs, &local)
+			fm.Resources = append(fm.Resources, local.Resources...)
+		}
+		bs, err := json.MarshalIndent(fm, "", "  ")
+		if err != nil {
+			return WrapErr("Marshal folder manifest", err)
+		}
+		if err := atomicWriteFile(filepath.Join(dir, folderManifestFile), bs, 0644); err != nil {
+			return WrapErr("WriteFile folder manifest", err)
+		}
+	}
+	return nil
+}
+
+func trimPaths(paths []string, prefix string) []string {
+	var trimmed []string
+	for _, p := range paths {
+		trimmed = append(trimmed, strings.TrimPrefix(p, prefix))
+	}
+	return trimmed
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"strings"
+	"time"
+)
+
+var (
+	frontMatter       = flag.Bool("frontMatter", false, "write a front matter with the doc metadata")
+	frontMatterFormat = flag.String("frontmatter-format", "yaml", "syntax of the front matter: yaml (---), toml (+++) or json")
+)
+
+// fmField is a front matter field, fields keep their order in the output.
+type fmField struct {
+	key   string
+	value interface{}
+}
+
+// docFrontMatter returns the front matter fields of the doc.
+func docFrontMatter(wizUser *WizUser, doc *Doc) []fmField {
+	fields := []fmField{
+		{"title", doc.Title},
+		{"docGuid", doc.DocGuid},
+		{"wiz_path", doc.Category},
+		{"wiz_kb", kbName(wizUser)},
+		{"wiz_kb_guid", wizUser.KbGuid},
+		{"tags", parseKeywords(doc.Keywords)},
+		// the color label used to group notes in wiz
+		{"color", doc.Color},
+	}
+	if doc.Created > 0 {
+		fields = append(fields, fmField{"created", docTime(doc.Created).Format(time.RFC3339)})
+	}
+	if doc.Modified > 0 {
+		fields = append(fields, fmField{"updated", docTime(doc.Modified).Format(time.RFC3339)})
+	}
+	if date := siteDate(doc); date != "" {
+		fields = append(fields, fmField{"date", date})
+	}
+	if isLinkDoc(doc) {
+		fields = append(fields, fmField{"type", "link"}, fmField{"url", doc.URL})
+	} else {
+		// the page a web clip is saved from
+		fields = append(fields, fmField{"source", doc.URL})
+	}
+	return fields
+}
+
+// volatileFields are the front matter fields changing with every version of a doc, left out of
+// the hash --incremental compares.
+var volatileFields = map[string]bool{"updated": true}
+
+// stableFields returns the fields without the volatile ones.
+func stableFields(fields []fmField) []fmField {
+	var stable []fmField
+	for _, f := range fields {
+		if !volatileFields[f.key] {
+			stable = append(stable, f)
+		}
+	}
+	return stable
+}
+
+// kbName returns the name of the knowledge base, the personal one has no name from the login.
+func kbName(wizUser *WizUser) string {
+	if wizUser.KbName != "" {
+		return wizUser.KbName
+	}
+	return "personal"
+}
+
+// renderFrontMatter renders the fields as yaml, empty values are omitted.
+func renderFrontMatter(fields []fmField) string {
+	fields = nonEmptyFields(fields)
+	var buf bytes.Buffer
+	switch *frontMatterFormat {
+	case "toml":
+		buf.WriteString("+++\n")
+		for _, f := range fields {
+			// json strings and string arrays are valid toml values
+			fmt.Fprintf(&buf, "%s = %s\n", f.key, jsonValue(f.value))
+		}
+		buf.WriteString("+++\n\n")
+	case "json":
+		buf.WriteString("{\n")
+		for i, f := range fields {
+			sep := ","
+			if i == len(fields)-1 {
+				sep = ""
+			}
+			fmt.Fprintf(&buf, "  %s: %s%s\n", jsonValue(f.key), jsonValue(f.value), sep)
+		}
+		buf.WriteString("}\n\n")
+	default:
+		buf.WriteString("---\n")
+		for _, f := range fields {
+			switch v := f.value.(type) {
+			case string:
+				fmt.Fprintf(&buf, "%s: %s\n", f.key, yamlString(v))
+			case []string:
+				fmt.Fprintf(&buf, "%s:\n", f.key)
+				for _, item := range v {
+					fmt.Fprintf(&buf, "  - %s\n", yamlString(item))
+				}
+			default:
+				fmt.Fprintf(&buf, "%s: %v\n", f.key, v)
+			}
+		}
+		buf.WriteString("---\n\n")
+	}
+	return buf.String()
+}
+
+func nonEmptyFields(fields []fmField) []fmField {
+	var kept []fmField
+	for _, f := range fields {
+		switch v := f.value.(type) {
+		case string:
+			if v == "" {
+				continue
+			}
+		case []string:
+			if len(v) == 0 {
+				continue
+			}
+		}
+		kept = append(kept, f)
+	}
+	return kept
+}
+
+// jsonValue marshals the value without escaping html.
+func jsonValue(v interface{}) string {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		return `""`
+	}
+	return strings.TrimSuffix(buf.String(), "\n")
+}
+
+// yamlString quotes the string, a json string is a valid yaml double quoted scalar.
+func yamlString(s string) string {
+	return jsonValue(s)
+}
+
+// siteFormat reports whether the export is for a static site generator, which needs the front matter.
+func siteFormat() bool {
+	return *format == "hugo" || *format == "jekyll"
+}
+
+// siteDate formats the created time as the date of the site generator, empty for the other formats.
+func siteDate(doc *Doc) string {
+	if doc.Created <= 0 {
+		return ""
+	}
+	switch *format {
+	case "hugo":
+		return docTime(doc.Created).Format(time.RFC3339)
+	case "jekyll":
+		return docTime(doc.Created).Format("2006-01-02 15:04:05 -0700")
+	}
+	return ""
+}
@@ -2,4 +2,10 @@ module github.com/GalaIO/wiz_export
 
 go 1.16
 
-require github.com/JohannesKaufmann/html-to-markdown v1.3.3
+require (
+	github.com/JohannesKaufmann/html-to-markdown v1.3.3
+	github.com/PuerkitoBio/goquery v1.5.1
+	golang.org/x/net v0.0.0-20200320220750-118fecf932d8
+	golang.org/x/term v0.0.0-20210615171337-6886f2dfbf5b
+	gopkg.in/yaml.v2 v2.2.8
+)
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	md "github.com/JohannesKaufmann/html-to-markdown"
+	"github.com/PuerkitoBio/goquery"
+)
+
+// max length of the nearby text used as alt
+const maxAltLen = 40
+
+// imageAlts converts images filling the alt from the alt or title attributes,
+// the figure caption or the short text next to the image.
+func imageAlts() md.Plugin {
+	return func(c *md.Converter) []md.Rule {
+		return []md.Rule{{
+			Filter: []string{"img"},
+			Replacement: func(content string, selec *goquery.Selection, opt *md.Options) *string {
+				src := strings.TrimSpace(selec.AttrOr("src", ""))
+				if src == "" {
+					return md.String("")
+				}
+				src = opt.GetAbsoluteURL(selec, src, "")
+				text := fmt.Sprintf("![%s](%s)", imageAlt(selec), src)
+				return &text
+			},
+		}}
+	}
+}
+
+func imageAlt(selec *goquery.Selection) string {
+	candidates := []string{
+		selec.AttrOr("alt", ""),
+		selec.AttrOr("title", ""),
+		selec.Closest("figure").Find("figcaption").First().Text(),
+	}
+	// a short line of text sharing the block with the image, like a caption
+	if parent := selec.Parent(); parent.Find("img").Length() == 1 {
+		if text := cleanAlt(parent.Text()); utf8.RuneCountInString(text) <= maxAltLen {
+			candidates = append(candidates, text)
+		}
+	}
+	for _, alt := range candidates {
+		if alt = cleanAlt(alt); alt != "" {
+			return alt
+		}
+	}
+	return ""
+}
+
+func cleanAlt(alt string) string {
+	alt = strings.NewReplacer("[", "", "]", "", "\\", "").Replace(alt)
+	return strings.Join(strings.Fields(alt), " ")
+}
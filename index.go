@@ -0,0 +1,69 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	indexJSONFile = "index.json"
+	indexMdFile   = "INDEX.md"
+)
+
+// Index lists the exported docs, its fields are kept stable for other tools to parse.
+type Index struct {
+	Generated string        `json:"generated"`
+	Count     int           `json:"count"`
+	Docs      []*IndexEntry `json:"docs"`
+}
+
+type IndexEntry struct {
+	Title   string `json:"title"`
+	DocGuid string `json:"docGuid"`
+	// Path is relative to the output, slash separated, the first part of a split doc
+	Path      string `json:"path"`
+	Created   string `json:"created"`
+	Resources int    `json:"resources"`
+}
+
+// writeIndex writes index.json and INDEX.md listing the docs of the manifest, ordered by path.
+func writeIndex(root string) error {
+	index := &Index{Generated: time.Now().Format(time.RFC3339), Docs: []*IndexEntry{}}
+	var md bytes.Buffer
+	md.WriteString("# Index\n\n| title | created | resources |\n| --- | --- | --- |\n")
+	for _, entry := range buildManifest().Docs {
+		created := ""
+		if entry.Created > 0 {
+			created = docTime(entry.Created).Format(time.RFC3339)
+		}
+		index.Docs = append(index.Docs, &IndexEntry{
+			Title:     entry.Title,
+			DocGuid:   entry.DocGuid,
+			Path:      entryLink(entry),
+			Created:   created,
+			Resources: len(entry.Resources),
+		})
+		fmt.Fprintf(&md, "| [%s](<%s>) | %s | %d |\n", escapeCell(entry.Title), entryLink(entry), created, len(entry.Resources))
+	}
+	index.Count = len(index.Docs)
+	bs, err := json.MarshalIndent(index, "", "  ")
+	if err != nil {
+		return WrapErr("Marshal index", err)
+	}
+	if err := atomicWriteFile(filepath.Join(root, indexJSONFile), bs, 0644); err != nil {
+		return WrapErr("WriteFile index", err)
+	}
+	if err := atomicWriteFile(filepath.Join(root, indexMdFile), md.Bytes(), 0644); err != nil {
+		return WrapErr("WriteFile index md", err)
+	}
+	return nil
+}
+
+// escapeCell escapes the pipes of a markdown table cell.
+func escapeCell(s string) string {
+	return strings.ReplaceAll(s, "|", "\\|")
+}
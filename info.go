@@ -0,0 +1,72 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"path/filepath"
+	"runtime"
+	"runtime/debug"
+	"sync/atomic"
+	"time"
+)
+
+const exportInfoFile = "_export_info.json"
+
+var (
+	// version is set by -ldflags "-X main.version=v1.0.0", go install uses the module version
+	version = "dev"
+	// flags whose values are masked in the export info
+	sensitiveFlags = map[string]bool{"password": true, "token": true}
+
+	docsExported int64
+)
+
+type ExportInfo struct {
+	Version   string            `json:"version"`
+	GoVersion string            `json:"goVersion"`
+	Platform  string            `json:"platform"`
+	Started   string            `json:"started"`
+	Finished  string            `json:"finished"`
+	KbGuid    string            `json:"kbGuid"`
+	Docs      int64             `json:"docs"`
+	Args      map[string]string `json:"args"`
+}
+
+func toolVersion() string {
+	if version != "dev" {
+		return version
+	}
+	if info, ok := debug.ReadBuildInfo(); ok && info.Main.Version != "" && info.Main.Version != "(devel)" {
+		return info.Main.Version
+	}
+	return version
+}
+
+// writeExportInfo records how and when the export was made next to it.
+func writeExportInfo(root string, wizUser *WizUser, started time.Time) error {
+	info := &ExportInfo{
+		Version:   toolVersion(),
+		GoVersion: runtime.Version(),
+		Platform:  runtime.GOOS + "/" + runtime.GOARCH,
+		Started:   started.Format(time.RFC3339),
+		Finished:  time.Now().Format(time.RFC3339),
+		KbGuid:    wizUser.KbGuid,
+		Docs:      atomic.LoadInt64(&docsExported),
+		Args:      make(map[string]string),
+	}
+	flag.VisitAll(func(f *flag.Flag) {
+		value := f.Value.String()
+		if sensitiveFlags[f.Name] && value != "" {
+			value = "******"
+		}
+		info.Args[f.Name] = value
+	})
+	bs, err := json.MarshalIndent(info, "", "  ")
+	if err != nil {
+		return WrapErr("Marshal export info", err)
+	}
+	if err := atomicWriteFile(filepath.Join(root, exportInfoFile), bs, 0644); err != nil {
+		return WrapErr("WriteFile export info", err)
+	}
+	return nil
+}
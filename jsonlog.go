@@ -0,0 +1,69 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"os"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+var jsonLog = flag.Bool("jsonLog", false, "print the events of the export as json lines instead of the log: login, folder_start, doc_exported, res_downloaded, error and summary")
+
+// LogEvent is a line of --jsonLog.
+type LogEvent struct {
+	Time    string `json:"timestamp"`
+	Type    string `json:"type"`
+	KbGuid  string `json:"kbGuid,omitempty"`
+	DocGuid string `json:"docGuid,omitempty"`
+	Folder  string `json:"folder,omitempty"`
+	// Path is relative to the output
+	Path    string        `json:"path,omitempty"`
+	Error   string        `json:"error,omitempty"`
+	Elapsed string        `json:"elapsed,omitempty"`
+	Counts  *StatusCounts `json:"counts,omitempty"`
+}
+
+// logEvent prints the event as a json line, nothing without --jsonLog.
+func logEvent(event *LogEvent) {
+	if !*jsonLog {
+		return
+	}
+	event.Time = time.Now().Format(time.RFC3339Nano)
+	bs, err := json.Marshal(event)
+	if err != nil {
+		return
+	}
+	logMu.Lock()
+	defer logMu.Unlock()
+	os.Stdout.Write(append(bs, '\n'))
+}
+
+// errorEvent prints the error lines of the log as error events.
+func errorEvent(msg string) {
+	logEvent(&LogEvent{Type: "error", Error: strings.TrimSpace(msg)})
+}
+
+// taskErrorEvent prints the failure of a task queued for retry.
+func taskErrorEvent(task *retryTask) {
+	event := &LogEvent{Type: "error", Error: task.name + ": " + task.err.Error()}
+	switch {
+	case task.res != nil:
+		event.Path = task.res.Path
+	case task.doc != nil:
+		event.DocGuid, event.Folder = task.doc.DocGuid, task.doc.Folder
+	}
+	logEvent(event)
+}
+
+// resDownloaded counts the downloaded resource and prints its event.
+func resDownloaded(docGuid, resPath string) {
+	atomic.AddInt64(&resFetched, 1)
+	logEvent(&LogEvent{Type: "res_downloaded", DocGuid: docGuid, Path: relPath(resPath)})
+}
+
+// docEvent prints the event of the exported doc, at its first part if split.
+func docEvent(entry *ManifestEntry) {
+	logEvent(&LogEvent{Type: "doc_exported", DocGuid: entry.DocGuid, Folder: entry.Category, Path: entryLink(entry)})
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+var (
+	kbLayout = flag.Bool("kbLayout", false, "export the personal kb into personal/ and team kbs into team/<kb name>/, each with its own manifest and resources")
+	kbSelect = flag.String("kb", "", "export the kbs by name or kbGuid into <output>/<kb name>/, like personal,团队A, see --listKb")
+	allKb    = flag.Bool("allKb", false, "export the personal kb and all the team kbs, each into <output>/<kb name>/")
+	listKb   = flag.Bool("listKb", false, "list the personal kb and the team kbs of the account, then exit")
+)
+
+// exportRoot is the folder of the kb exported, the paths of the manifest are relative to it
+var exportRoot = "."
+
+func isPersonalKb(wizUser *WizUser) bool {
+	switch strings.ToLower(wizUser.KbType) {
+	case "", "person", "personal", "private":
+		return true
+	}
+	return false
+}
+
+// kbRoot returns the export folder of the kb under the output.
+func kbRoot(output string, wizUser *WizUser) string {
+	if !*kbLayout {
+		if multiKb() {
+			return filepath.Join(output, sanitizeFileName(kbName(wizUser)))
+		}
+		return output
+	}
+	if isPersonalKb(wizUser) {
+		return filepath.Join(output, "personal")
+	}
+	return filepath.Join(output, "team", sanitizeFileName(kbName(wizUser)))
+}
+
+func multiKb() bool {
+	return *kbSelect != "" || *allKb
+}
+
+// accountKbs returns the personal kb of the login and the team kbs of the account.
+func accountKbs(wizUser *WizUser) ([]*WizUser, error) {
+	groups, err := kbClient(wizUser).Groups()
+	if err != nil {
+		return nil, err
+	}
+	kbs := []*WizUser{wizUser}
+	for _, g := range groups {
+		kb := *wizUser
+		kb.KbGuid, kb.KbServer, kb.KbName, kb.KbType = g.KbGuid, strings.TrimRight(g.KbServer, "/"), g.Name, "group"
+		kbs = append(kbs, &kb)
+	}
+	return kbs, nil
+}
+
+// selectKbs returns the kbs to export: the kb of the login, or those of --kb and --allKb.
+func selectKbs(wizUser *WizUser) ([]*WizUser, error) {
+	if !multiKb() {
+		return []*WizUser{wizUser}, nil
+	}
+	kbs, err := accountKbs(wizUser)
+	if err != nil {
+		return nil, err
+	}
+	if *allKb {
+		return kbs, nil
+	}
+	var selected []*WizUser
+	for _, name := range strings.Split(*kbSelect, ",") {
+		if name = strings.TrimSpace(name); name == "" {
+			continue
+		}
+		found := false
+		for _, kb := range kbs {
+			if kbName(kb) == name || strings.EqualFold(kb.KbGuid, name) {
+				selected = append(selected, kb)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("unknown kb: %s, see --listKb", name)
+		}
+	}
+	return selected, nil
+}
+
+func printKbs(wizUser *WizUser) error {
+	kbs, err := accountKbs(wizUser)
+	if err != nil {
+		return err
+	}
+	logResultf("Kb info:\n\tcount: %v\n", len(kbs))
+	for _, kb := range kbs {
+		logResultf("\t%s: %s %s\n", kbName(kb), kb.KbGuid, kb.KbServer)
+	}
+	return nil
+}
+
+// resetKb clears the files and docs of the kb exported before, which are per kb folder.
+func resetKb() {
+	setPrevManifest(new(Manifest))
+	manifestMu.Lock()
+	exportedDocs = make(map[string]*ManifestEntry)
+	pendingThumbs = make(map[string]map[string]string)
+	listedDocs = make(map[string]bool)
+	listedFolders = make(map[string]bool)
+	manifestMu.Unlock()
+
+	stateMu.Lock()
+	docStates = make(map[string]*DocState)
+	stateMu.Unlock()
+	docFilesMu.Lock()
+	docFiles = make(map[string]string)
+	docFilesMu.Unlock()
+	attachmentsMu.Lock()
+	attachmentFiles = make(map[string]bool)
+	attachmentOwners = make(map[string]string)
+	attachmentsMu.Unlock()
+	assetsMu.Lock()
+	assetsMap = make(map[string]string)
+	assetsMu.Unlock()
+	ankiMu.Lock()
+	ankiCards = nil
+	ankiMu.Unlock()
+	largeResMu.Lock()
+	largeRes = nil
+	largeResMu.Unlock()
+	emptyMu.Lock()
+	emptyFolders = make(map[string]string)
+	emptyMu.Unlock()
+	resetCounts()
+	resetFailed()
+}
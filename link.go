@@ -0,0 +1,28 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// wiz doc types of bookmark like notes, pointing to another url
+var linkTypes = map[string]bool{"url": true, "link": true, "bookmark": true}
+
+// isLinkDoc reports whether the doc is a link note.
+func isLinkDoc(doc *Doc) bool {
+	return linkTypes[strings.ToLower(doc.Type)] && doc.URL != ""
+}
+
+// linkMarkdown keeps the target and the description of a link note, which has almost no body.
+func linkMarkdown(doc *Doc, markdown string) string {
+	link := fmt.Sprintf("# %s\n\n<%s>\n", doc.Title, doc.URL)
+	if desc := strings.TrimSpace(markdown); desc != "" {
+		link += "\n" + desc + "\n"
+	}
+	return link
+}
+
+// sourceLine keeps where a web clip comes from on top of the doc.
+func sourceLine(url string) string {
+	return fmt.Sprintf("来源：<%s>\n\n", url)
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+const notExportedMark = " *(not exported)*"
+
+var (
+	// the links between the wiz docs, like [a](wiz://open_document?guid=xx&kbguid=xx), and their mark
+	// when the doc is out of the export
+	wizLinkRe = regexp.MustCompile(`\]\((wiz://open_document\?[^)\s]*)\)(` + regexp.QuoteMeta(notExportedMark) + `)?`)
+	wizGuidRe = regexp.MustCompile(`[?&]guid=([0-9A-Za-z-]+)`)
+)
+
+// rewriteDocLinks points the wiz links of the exported markdown to the local files of the docs,
+// it runs after the export when the files of all the docs are known. The links to docs out of
+// the export are kept and marked.
+func rewriteDocLinks() error {
+	m := buildManifest()
+	docs := make(map[string]*ManifestEntry, len(m.Docs))
+	for _, entry := range m.Docs {
+		docs[strings.ToLower(entry.DocGuid)] = entry
+	}
+	for _, entry := range m.Docs {
+		files := entry.Parts
+		if len(files) == 0 {
+			files = []string{entry.Path}
+		}
+		for _, file := range files {
+			if !strings.HasSuffix(file, ".md") {
+				continue
+			}
+			data, _, err := rewriteFileLinks(file, docs)
+			if err != nil {
+				return err
+			}
+			// the hash covers the whole doc, not its split parts. The file may be rewritten in
+			// this run or kept unchanged from the last, the hash of the converted doc is kept
+			// for --incremental to compare.
+			if data != nil && len(entry.Parts) == 0 {
+				if hash := hashContent(data); hash != entry.Hash {
+					if entry.SourceHash == "" {
+						entry.SourceHash = entry.Hash
+					}
+					entry.Hash = hash
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// rewriteFileLinks rewrites the wiz links of the file, slash separated and relative to the output,
+// keeping its modified time.
+func rewriteFileLinks(file string, docs map[string]*ManifestEntry) ([]byte, bool, error) {
+	local := localPath(exportRoot, file)
+	info, err := os.Stat(local)
+	if os.IsNotExist(err) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, WrapErr("stat doc", err)
+	}
+	bs, err := ioutil.ReadFile(local)
+	if err != nil {
+		return nil, false, WrapErr("read doc", err)
+	}
+	content := mapOutsideCode(string(bs), func(s string) string {
+		return wizLinkRe.ReplaceAllStringFunc(s, func(m string) string {
+			link := wizLinkRe.FindStringSubmatch(m)[1]
+			if guid := wizGuidRe.FindStringSubmatch(link); guid != nil {
+				if target, ok := docs[strings.ToLower(guid[1])]; ok {
+					return "](<" + relLink(file, entryLink(target)) + ">)"
+				}
+			}
+			return "](" + link + ")" + notExportedMark
+		})
+	})
+	if content == string(bs) {
+		return bs, false, nil
+	}
+	if err := writeDoc(local, []byte(content)); err != nil {
+		return nil, false, WrapErr("WriteFile links", err)
+	}
+	if err := os.Chtimes(local, info.ModTime(), info.ModTime()); err != nil {
+		logErrorf("\tset times %s err: %v\n", local, err)
+	}
+	return []byte(content), true, nil
+}
+
+// relLink returns the link from the file to the target, both slash separated and relative to the output.
+func relLink(file, target string) string {
+	rel, err := filepath.Rel(filepath.FromSlash(path.Dir(file)), filepath.FromSlash(target))
+	if err != nil {
+		return target
+	}
+	return filepath.ToSlash(rel)
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"flag"
+	"sync"
+)
+
+var (
+	pageSize        = flag.Int("pageSize", 200, "docs per page of the folder list")
+	listConcurrency = flag.Int("listConcurrency", 4, "pages of a large folder list fetched at once, still paced by --interval")
+)
+
+// fetchDocPage fetches one page of the folder list.
+func fetchDocPage(wizUser *WizUser, folder string, start, count int) ([]*Doc, error) {
+	return kbClient(wizUser).DocPage(folder, start, count)
+}
+
+// listFolderDocs fetches all the pages of the folder list, probing the pages after
+// the first one --listConcurrency at a time until a short page. A full last page
+// is followed by the request of an empty one.
+func listFolderDocs(wizUser *WizUser, folder string) ([]*Doc, error) {
+	size := *pageSize
+	if size < 1 {
+		size = 200
+	}
+	docs, err := fetchDocPage(wizUser, folder, 0, size)
+	if err != nil || len(docs) < size {
+		return docs, err
+	}
+	n := *listConcurrency
+	if n < 1 {
+		n = 1
+	}
+	seen := make(map[string]bool, len(docs))
+	for _, doc := range docs {
+		seen[doc.DocGuid] = true
+	}
+	for start := size; ; start += n * size {
+		pages := make([][]*Doc, n)
+		errs := make([]error, n)
+		var wg sync.WaitGroup
+		for i := 0; i < n; i++ {
+			i := i
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				pages[i], errs[i] = fetchDocPage(wizUser, folder, start+i*size, size)
+			}()
+		}
+		wg.Wait()
+		for i, page := range pages {
+			if errs[i] != nil {
+				return nil, errs[i]
+			}
+			for _, doc := range page {
+				if !seen[doc.DocGuid] {
+					seen[doc.DocGuid] = true
+					docs = append(docs, doc)
+				}
+			}
+			if len(page) < size {
+				return docs, nil
+			}
+		}
+	}
+}
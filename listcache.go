@@ -0,0 +1,59 @@
+package main
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+var (
+	useListCache = flag.Bool("use-list-cache", false, "reuse the folder lists cached under <output>/.cache/lists, handy when tuning the conversion")
+	listCacheTTL = flag.Duration("listCacheTTL", time.Hour, "how long a cached folder list is used")
+	refreshList  = flag.Bool("refresh-list-cache", false, "with --use-list-cache, fetch the folder lists again and refresh the cache")
+)
+
+func listCacheFile(wizUser *WizUser, folder string) string {
+	sum := sha1.Sum([]byte(wizUser.KbGuid + folder))
+	return filepath.Join(*output, ".cache", "lists", hex.EncodeToString(sum[:])+".json")
+}
+
+// cachedFolderDocs returns the folder list from the cache while it is fresh, fetching and caching it otherwise.
+func cachedFolderDocs(wizUser *WizUser, folder string) ([]*Doc, error) {
+	if !*useListCache {
+		return listFolderDocs(wizUser, folder)
+	}
+	file := listCacheFile(wizUser, folder)
+	if info, err := os.Stat(file); err == nil && !*refreshList && time.Since(info.ModTime()) < *listCacheTTL {
+		bs, err := ioutil.ReadFile(file)
+		if err != nil {
+			return nil, WrapErr("read list cache", err)
+		}
+		var docs []*Doc
+		if err := json.Unmarshal(bs, &docs); err != nil {
+			return nil, WrapErr("Unmarshal list cache", err)
+		}
+		logDebugf("\tlist cache: %s, docs: %v\n", folder, len(docs))
+		return docs, nil
+	}
+
+	docs, err := listFolderDocs(wizUser, folder)
+	if err != nil {
+		return nil, err
+	}
+	bs, err := json.Marshal(docs)
+	if err != nil {
+		return nil, WrapErr("Marshal list cache", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		return nil, WrapErr("MkdirAll list cache", err)
+	}
+	if err := atomicWriteFile(file, bs, 0644); err != nil {
+		return nil, WrapErr("WriteFile list cache", err)
+	}
+	return docs, nil
+}
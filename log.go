@@ -0,0 +1,71 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"sync"
+)
+
+var (
+	verbose = flag.Bool("verbose", false, "print the details, like every request and resource")
+	quiet   = flag.Bool("quiet", false, "print only the errors and the summary")
+
+	// logMu keeps the lines of a log block together among the workers
+	logMu sync.Mutex
+)
+
+const (
+	// errors and the summary, always printed
+	levelError = iota
+	// the progress of folders and docs
+	levelInfo
+	// requests and resources, printed by --verbose
+	levelDebug
+)
+
+func logLevel() int {
+	switch {
+	case *quiet:
+		return levelError
+	case *verbose:
+		return levelDebug
+	}
+	return levelInfo
+}
+
+func logf(level int, format string, args ...interface{}) {
+	if *jsonLog {
+		// the errors are events, the rest of the log gives way to the events
+		if level == levelError {
+			errorEvent(fmt.Sprintf(format, args...))
+		}
+		return
+	}
+	if level > logLevel() {
+		return
+	}
+	logMu.Lock()
+	defer logMu.Unlock()
+	fmt.Printf(format, args...)
+}
+
+// logErrorf prints errors and the summary, which --quiet keeps.
+func logErrorf(format string, args ...interface{}) {
+	logf(levelError, format, args...)
+}
+
+// logResultf prints the summaries and listings, which --quiet keeps and --jsonLog leaves out.
+func logResultf(format string, args ...interface{}) {
+	if *jsonLog {
+		return
+	}
+	logf(levelError, format, args...)
+}
+
+func logInfof(format string, args ...interface{}) {
+	logf(levelInfo, format, args...)
+}
+
+func logDebugf(format string, args ...interface{}) {
+	logf(levelDebug, format, args...)
+}
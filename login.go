@@ -0,0 +1,90 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/GalaIO/wiz_export/wiz"
+	"golang.org/x/term"
+)
+
+const (
+	userIdEnv   = "WIZ_USER_ID"
+	passwordEnv = "WIZ_PASSWORD"
+)
+
+var (
+	loginFields   = flag.String("loginFields", "", "map renamed fields of the login result, like token=accessToken,kbServer=kb_server")
+	server        = flag.String("server", wiz.DefaultServer, "account server to login, for private deployments")
+	loginToken    = flag.String("token", "", "token to export with instead of login, needs --kbServer and --kbGuid")
+	loginKbServer = flag.String("kbServer", "", "kb server of the token")
+	loginKbGuid   = flag.String("kbGuid", "", "kb guid of the token")
+	verifyCode    = flag.String("verifyCode", "", "verification code of the login, asked on the terminal if needed and empty")
+	verifyField   = flag.String("verifyField", "authCode", "login field of the verification code")
+)
+
+// loginEnv fills the empty --userId and --password from $WIZ_USER_ID and $WIZ_PASSWORD,
+// or asks the password on the terminal without echo. The prompts go to stderr, out of the
+// --jsonLog lines on stdout.
+func loginEnv() error {
+	if *userId == "" {
+		*userId = os.Getenv(userIdEnv)
+	}
+	if *password == "" {
+		*password = os.Getenv(passwordEnv)
+	}
+	if *password != "" || *userId == "" || *loginToken != "" || !term.IsTerminal(int(os.Stdin.Fd())) {
+		return nil
+	}
+	fmt.Fprintf(os.Stderr, "Login info:\n\tenter the password of %s: ", *userId)
+	bs, err := term.ReadPassword(int(os.Stdin.Fd()))
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return WrapErr("read password", err)
+	}
+	*password = strings.TrimSpace(string(bs))
+	return nil
+}
+
+// verificationCode returns --verifyCode, or asks the user for the code on the terminal.
+func verificationCode(verify *wiz.VerifyError) (string, error) {
+	if *verifyCode != "" {
+		return *verifyCode, nil
+	}
+	if !term.IsTerminal(int(os.Stdin.Fd())) {
+		return "", fmt.Errorf("%w, pass it by --verifyCode", verify)
+	}
+	fmt.Fprintf(os.Stderr, "Login info:\n\t%s\n\tenter the verification code: ", verify.Message)
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil {
+		return "", WrapErr("read verification code", err)
+	}
+	code := strings.TrimSpace(line)
+	if code == "" {
+		return "", verify
+	}
+	return code, nil
+}
+
+// loginRenames parses --loginFields into the renames of the login result fields.
+func loginRenames() map[string]string {
+	renames := make(map[string]string)
+	for _, pair := range strings.Split(*loginFields, ",") {
+		if kv := strings.SplitN(pair, "=", 2); len(kv) == 2 {
+			renames[strings.TrimSpace(kv[0])] = strings.TrimSpace(kv[1])
+		}
+	}
+	return renames
+}
+
+// tokenLogin returns the user of --token, which skips the login.
+func tokenLogin() (*WizUser, error) {
+	if *loginKbServer == "" || *loginKbGuid == "" {
+		return nil, errors.New("--token needs --kbServer and --kbGuid")
+	}
+	return &WizUser{Token: *loginToken, KbServer: strings.TrimRight(*loginKbServer, "/"), KbGuid: *loginKbGuid}, nil
+}
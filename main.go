@@ -1,194 +1,722 @@
 package main
 
 import (
-	"bytes"
-	"encoding/json"
+	"context"
 	"errors"
 	"flag"
 	"fmt"
 	md "github.com/JohannesKaufmann/html-to-markdown"
 	"github.com/JohannesKaufmann/html-to-markdown/plugin"
-	"io/ioutil"
+	"math/rand"
+	"net"
 	"net/http"
-	"net/url"
 	"os"
-	"path"
-	"regexp"
+	"path/filepath"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
-)
-
-type ResultCode struct {
-	ReturnCode    int    `json:"returnCode"`
-	ReturnMessage string `json:"returnMessage"`
-}
-
-type WizUserResult struct {
-	ResultCode
-	Result *WizUser `json:"result"`
-}
-
-type WizUser struct {
-	UserGuid    string `json:"userGuid"`
-	Email       string `json:"email"`
-	Mobile      string `json:"mobile"`
-	DisplayName string `json:"displayName"`
-	KbType      string `json:"kbType"`
-	KbServer    string `json:"kbServer"`
-	Token       string `json:"token"`
-	KbGuid      string `json:"kbGuid"`
-}
 
-type DocListResult struct {
-	ResultCode
-	Result []*Doc `json:"result"`
-}
+	"github.com/GalaIO/wiz_export/wiz"
+)
 
-type Doc struct {
-	DocGuid         string `json:"docGuid"`
-	Title           string `json:"title"`
-	Category        string `json:"category"`
-	AttachmentCount int    `json:"attachmentCount"`
-	Created         int    `json:"created"`
-	Accessed        int    `json:"accessed"`
-	Keywords        string `json:"keywords"`
-	CoverImage      string `json:"coverImage"`
-}
+// the api types live in the wiz package, which the export uses as a library
+type (
+	ResultCode    = wiz.ResultCode
+	WizUser       = wiz.User
+	DocListResult = wiz.DocListResult
+	Doc           = wiz.Doc
+)
 
 var (
 	conv     = md.NewConverter("", true, nil)
-	userId   = flag.String("userId", "", "wiz userId")
-	password = flag.String("password", "", "wiz password")
+	userId   = flag.String("userId", "", "wiz userId, default $WIZ_USER_ID")
+	password = flag.String("password", "", "wiz password, default $WIZ_PASSWORD or asked on the terminal")
 	output   = flag.String("output", ".", "export output")
 	folders  = flag.String("folders", "", "export folders, like /日记/,/Logs/")
+	format   = flag.String("format", "md", "export format: md, html (the wiz html as is), both (md and html), mkdocs (docs/ and mkdocs.yml), hugo, jekyll (front matter with dates, dated file names), anki (experimental, question/answer cards split by headings)")
 )
 
 // usage
 // wiz_export --output '/Users/xx/' --userId 'xx' --password 'xx' --folders '/日记/,/工作/'
+// wiz_export --config config.yaml
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "verify" {
+		os.Exit(runVerify(os.Args[2:]))
+	}
 	flag.Parse()
-	if *userId == "" || *password == "" || *folders == "" {
+	PanicErr(loadConfig())
+	PanicErr(loginEnv())
+	if *loginToken == "" && (*userId == "" || *password == "") {
 		fmt.Println("err args:")
 		flag.PrintDefaults()
-		panic("empty user or folders")
+		panic("empty user")
+	}
+	started := time.Now()
+	if *maxDisk != "" {
+		limit, err := parseSize(*maxDisk)
+		PanicErr(WrapErr("parse max-disk", err))
+		diskLimit = limit
 	}
-	root := *output
+	if *accessedSince != "" {
+		since, err := parseSince(*accessedSince)
+		PanicErr(WrapErr("parse accessed-since", err))
+		accessedAfter = since
+	}
+	PanicErr(parseCreatedRange())
+	PanicErr(parseTitleMatch())
+	PanicErr(checkOnExisting())
+	if *maxResSize != "" {
+		size, err := parseSize(*maxResSize)
+		PanicErr(WrapErr("parse max-res-size", err))
+		maxResBytes = size
+	}
+	if *breadcrumbs {
+		*folderIndex = true
+	}
+	docGate.SetLimit(*concurrency)
+	if *maxTasks > 0 {
+		taskGate = newGate(*maxTasks)
+	}
+	requestPacer.SetInterval(*interval)
+	requestPacer.SetBurst(*burst)
+	if *control != "" {
+		go watchControl(*control)
+	}
+	watchPauseSignal()
+	watchInterrupt()
+	PanicErr(setupClient())
 
+	opts, err := converterOptions()
+	PanicErr(err)
+	conv = md.NewConverter("", true, opts)
 	// Use the `GitHubFlavored` plugin from the `plugin` package.
-	conv.Use(plugin.GitHubFlavored())
-	wizUser, err := Login(*userId, *password)
+	conv.Use(plugin.GitHubFlavored(), codeBlocks(), imageAlts(), inlineStyles(), wizTodos())
+	setupWizClient()
+	var wizUser *WizUser
+	if *loginToken != "" {
+		wizUser, err = tokenLogin()
+	} else {
+		wizUser, err = Login(*userId, *password)
+	}
+	PanicErr(err)
+	logEvent(&LogEvent{Type: "login", KbGuid: wizUser.KbGuid})
+	logInfof("User info:\n\tkbServer: %s\n\tkbGuid: %s\n", wizUser.KbServer, wizUser.KbGuid)
+	logDebugf("\ttoken: %s\n", wizUser.CurrentToken())
+	if *listKb {
+		PanicErr(printKbs(wizUser))
+		return
+	}
+	kbs, err := selectKbs(wizUser)
 	PanicErr(err)
-	fmt.Printf("User info:\n\tkbServer: %s\n\tkbGuid: %s\n\ttoken: %s\n",
-		wizUser.KbServer, wizUser.KbGuid, wizUser.Token)
+	statuses := make(map[string]*ExportStatus)
+	for i, kb := range kbs {
+		if exportStopped() {
+			break
+		}
+		if i > 0 {
+			resetKb()
+		}
+		if len(kbs) > 1 {
+			logInfof("Kb info:\n\tname: %s\n\tkbGuid: %s\n", kbName(kb), kb.KbGuid)
+		}
+		if status := exportKb(kb, time.Now()); status != nil && kbRoot(*output, kb) != *output {
+			statuses[kbName(kb)] = status
+		}
+	}
+	// the kbs in their folders get a status.json of the output too, at a fixed path
+	if len(statuses) > 0 {
+		if err := writeKbsStatus(*output, started, statuses); err != nil {
+			logErrorf("writeKbsStatus err: %v\n", err)
+		}
+	}
+}
+
+// exportKb exports the folders and collections of the kb into its folder under the output, and
+// returns its status, nil for a dry run or --retryRes.
+func exportKb(wizUser *WizUser, started time.Time) *ExportStatus {
+	probeServer = wizUser.KbServer
+	tokenUser = wizUser
+	root := kbRoot(*output, wizUser)
+	exportRoot = root
+	if *onExisting == "clean" && !*dryRun && *retryRes == "" {
+		PanicErr(cleanRoot(root))
+	}
+	if *format != "anki" {
+		m, err := loadManifest(filepath.Join(root, manifestFile))
+		PanicErr(err)
+		setPrevManifest(m)
+		reserveAttachments(m)
+		PanicErr(loadState(root))
+	}
+	if *retryRes != "" {
+		PanicErr(retryFailedRes(root, wizUser, *retryRes))
+		return nil
+	}
+	if list, err := loadFailedDocs(filepath.Join(root, failedDocsFile)); err == nil {
+		addPrevFailed(list)
+	} else if !os.IsNotExist(err) {
+		logErrorf("loadFailedDocs err: %v\n", err)
+	}
+
+	// the exported docs live under docs/ of a mkdocs site
+	docRoot := root
+	if *format == "mkdocs" {
+		docRoot = filepath.Join(root, mkdocsDocs)
+	}
+
+	if *retryFailed != "" {
+		PanicErr(retryFailedDocs(docRoot, wizUser, *retryFailed))
+	} else {
+		exportSources(docRoot, wizUser)
+	}
+
+	if *dryRun {
+		printDryRun()
+		return nil
+	}
+	failed := drainRetryQueue()
+	if len(failed) > 0 {
+		logResultf("Failed:\n\tcount: %v\n", len(failed))
+		for _, task := range failed {
+			logResultf("\t%s: %v\n", task.name, task.err)
+		}
+	}
+	printLargeRes()
+	if err := writeFailedRes(root, failed); err != nil {
+		stepErr("writeFailedRes", err)
+	}
+	if err := writeFailedDocs(root, failed); err != nil {
+		stepErr("writeFailedDocs", err)
+	}
+	if *format != "anki" {
+		if err := rewriteDocLinks(); err != nil {
+			stepErr("rewriteDocLinks", err)
+		}
+	}
+	if *format == "anki" {
+		if err := writeAnki(); err != nil {
+			stepErr("writeAnki", err)
+		}
+	} else if diff, err := saveManifest(root); err != nil {
+		stepErr("saveManifest", err)
+	} else if *snapshot != "" {
+		if err := writeSnapshot(root, diff); err != nil {
+			stepErr("writeSnapshot", err)
+		}
+	}
+	if *format != "anki" {
+		if err := saveState(root); err != nil {
+			stepErr("saveState", err)
+		}
+		if err := writeIndex(root); err != nil {
+			stepErr("writeIndex", err)
+		}
+	}
+	if *folderManifest && *format != "anki" {
+		if err := writeFolderManifests(docRoot); err != nil {
+			stepErr("writeFolderManifests", err)
+		}
+	}
+	if err := saveAssetsMap(root); err != nil {
+		stepErr("saveAssetsMap", err)
+	}
+	if *tagIndex {
+		if err := writeTagIndex(root); err != nil {
+			stepErr("writeTagIndex", err)
+		}
+	}
+	if *folderTags && *format != "anki" {
+		if err := writeFolderTags(root); err != nil {
+			stepErr("writeFolderTags", err)
+		}
+	}
+	if *todos && *format != "anki" {
+		if err := writeTodos(root); err != nil {
+			stepErr("writeTodos", err)
+		}
+	}
+	if *format == "mkdocs" {
+		if err := writeMkdocs(root, wizUser); err != nil {
+			stepErr("writeMkdocs", err)
+		}
+	}
+	if err := writeExportInfo(root, wizUser, started); err != nil {
+		stepErr("writeExportInfo", err)
+	}
+	if *zipOutput != "" {
+		if err := writeZip(root); err != nil {
+			stepErr("writeZip", err)
+		}
+	}
+	printReadingInfo()
+	printDiskInfo()
+	printSummary(started, failed)
+	status, err := writeStatus(root, started, failed)
+	if err != nil {
+		logErrorf("writeStatus err: %v\n", err)
+	}
+	return status
+}
 
-	folderArr := strings.Split(*folders, ",")
+// exportSources exports the folders, docs and collections of the flags.
+func exportSources(docRoot string, wizUser *WizUser) {
+	var folderArr []string
+	if *folders != "" {
+		for _, folder := range strings.Split(*folders, ",") {
+			if strings.TrimSpace(folder) != "" {
+				folderArr = append(folderArr, normalizeFolder(folder))
+			}
+		}
+	}
+	if exportAll() {
+		categories, err := fetchCategories(wizUser)
+		PanicErr(err)
+		// parents before children
+		folderArr = sortedFolders(categories)
+	} else if *recursive && len(folderArr) > 0 {
+		categories, err := fetchCategories(wizUser)
+		PanicErr(err)
+		folderArr = expandFolders(folderArr, categories)
+	}
 	for _, folder := range folderArr {
-		fmt.Printf("Folder info:\n\tfolder: %s\n", folder)
-		if err := fetchFolder(root, wizUser, folder); err != nil {
-			fmt.Println("fetchFolder err:", err)
+		if exportStopped() {
+			break
+		}
+		logInfof("Folder info:\n\tfolder: %s\n", folder)
+		folder := folder
+		if err := fetchFolder(docRoot, wizUser, folder); err != nil {
+			enqueueFolderRetry(docRoot, wizUser, folder, err)
 		}
+	}
 
-		time.Sleep(100 * time.Millisecond)
+	if *docGuids != "" && !exportStopped() {
+		if *dryRun {
+			logInfof("Dry run:\n\tskip docGuids: %s\n", *docGuids)
+		} else {
+			fetchDocGuids(docRoot, wizUser)
+		}
 	}
 
+	for _, id := range strings.Split(*collections, ",") {
+		if id = strings.TrimSpace(id); id == "" || exportStopped() {
+			continue
+		}
+		if *dryRun {
+			logInfof("Dry run:\n\tskip collection: %s\n", id)
+			continue
+		}
+		logInfof("Collection info:\n\tid: %s\n", id)
+		id := id
+		if err := fetchCollection(docRoot, wizUser, id); err != nil {
+			enqueueRetry("collection "+id, err, func(context.Context) error {
+				return fetchCollection(docRoot, wizUser, id)
+			})
+		}
+	}
 }
 
 func fetchFolder(root string, wizUser *WizUser, folder string) error {
-	token := wizUser.Token
-	cbs, err := Fetch(fmt.Sprintf("%s/ks/note/list/category/%s?start=0&count=200&category=%s&orderBy=created",
-		wizUser.KbServer, wizUser.KbGuid, url.PathEscape(folder)), token)
-	if err != nil {
-		return WrapErr("fetch folder", err)
+	if *dryRun {
+		return previewFolder(root, wizUser, folder)
 	}
-	cateResult := new(DocListResult)
-	if err = json.Unmarshal(cbs, cateResult); err != nil {
-		return WrapErr("Unmarshal folder result", err)
+	logEvent(&LogEvent{Type: "folder_start", Folder: folder, Path: relPath(folderPath(root, folder))})
+	if *merge {
+		if err := mergeFolder(root, wizUser, folder); err != nil {
+			return err
+		}
+		folderReached(folder)
+		return nil
 	}
-	if cateResult.ReturnCode != 200 {
-		return WrapErr("fetch folder", err)
+	list, err := cachedFolderDocs(wizUser, folder)
+	if err != nil {
+		checkFolderDocs(wizUser, folder, 0, 0, err)
+		return err
 	}
-	// make root and resource folder
-	parentPath := path.Join(root, folder[1:])
+	recordListed(folder, list)
+	// make root folder
+	parentPath := folderPath(root, folder)
 	if err = os.MkdirAll(parentPath, 0755); err != nil {
 		return WrapErr("MkdirAll folder", err)
 	}
-
-	if err := os.MkdirAll(path.Join(parentPath, "index_files"), 0755); err != nil {
-		return WrapErr("MkdirAll index_files", err)
+	// read docs, the workers take them from the channel
+	docs := filterDocs(list)
+	checkFolderDocs(wizUser, folder, len(list), len(docs), nil)
+	atomic.AddInt64(&docsQueued, int64(len(docs)))
+	claimFolderFiles(parentPath, docs)
+	jobs := make(chan *Doc)
+	var wg sync.WaitGroup
+	for i := 0; i < workerCount(); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for doc := range jobs {
+				// the control file may change the concurrency at runtime
+				docGate.Acquire()
+				logInfof("Doc info: %s\n\tdocGuid: %s\n\ttitle: %s\n\tattachmentCount:%v\n",
+					progress(), doc.DocGuid, doc.Title, doc.AttachmentCount)
+				exportDoc(parentPath, wizUser, doc)
+				docGate.Release()
+			}
+		}()
 	}
-	// read docs
-	for _, doc := range cateResult.Result {
-		fmt.Printf("Doc info:\n\tdocGuid: %s\n\ttitle: %s\n\tattachmentCount:%v\n",
-			doc.DocGuid, doc.Title, doc.AttachmentCount)
-		if err := fetchDoc(parentPath, wizUser, doc); err != nil {
-			fmt.Println("fetchDoc err:", err)
+	for _, doc := range docs {
+		if exportStopped() {
+			break
 		}
-		time.Sleep(100 * time.Millisecond)
+		jobs <- doc
 	}
-
+	close(jobs)
+	wg.Wait()
+	if *folderIndex {
+		if err := writeFolderIndex(parentPath, folder, parentIndex(folder), sortDocs(docs)); err != nil {
+			return err
+		}
+	}
+	folderReached(folder)
 	return nil
 }
 
-func fetchDoc(root string, wizUser *WizUser, doc *Doc) error {
-	token := wizUser.Token
-	docName := doc.Title
-	if !strings.HasSuffix(docName, ".md") {
-		docName = docName + ".md"
+// folderReached drops the folder exported from failed.json, unless the export stopped in it.
+func folderReached(folder string) {
+	if !exportStopped() {
+		reachFailed(&FailedDoc{Folder: folder})
 	}
-	html, err := Fetch(fmt.Sprintf("%s/ks/note/view/%s/%s?objType=document",
-		wizUser.KbServer, wizUser.KbGuid, doc.DocGuid), token)
-	if err != nil {
-		return WrapErr("fetch doc", err)
+}
+
+// exportDoc exports the doc and counts it, failures are queued for retry.
+func exportDoc(root string, wizUser *WizUser, doc *Doc) {
+	// the doc failing again is listed in failed.json by its task
+	reachFailed(failedDoc(doc))
+	if skipUnchanged(doc) || skipExisting(root, doc) {
+		return
+	}
+	run := func(ctx context.Context) error {
+		if err := fetchDoc(ctx, root, wizUser, doc); err != nil {
+			return err
+		}
+		recordState(doc)
+		atomic.AddInt64(&docsExported, 1)
+		return nil
 	}
+	runTask(&retryTask{name: "doc " + doc.DocGuid + " " + doc.Title, run: run, doc: failedDoc(doc)})
+}
 
-	markdown, err := conv.ConvertString(string(html))
-	if err != nil {
-		return WrapErr("ConvertString", err)
+func enqueueFolderRetry(root string, wizUser *WizUser, folder string, err error) {
+	queueRetry(&retryTask{
+		name: "folder " + folder,
+		err:  err,
+		run: func(context.Context) error {
+			return fetchFolder(root, wizUser, folder)
+		},
+		doc: &FailedDoc{Folder: folder},
+	})
+}
+
+func fetchDoc(ctx context.Context, root string, wizUser *WizUser, doc *Doc) error {
+	// the doc holds a task until its resources, which take their own
+	release := acquireTask()
+	defer release()
+	var markdown string
+	var html []byte
+	if *markdownOnly {
+		var err error
+		if html, err = taskClient(ctx, wizUser).DocHTML(doc.DocGuid); err != nil {
+			return err
+		}
+		if markdown, err = rawMarkdown(string(html)); err != nil {
+			return WrapErr("rawMarkdown", err)
+		}
+	} else {
+		exported, err := taskClient(ctx, wizUser).ExportDoc(doc)
+		if err != nil {
+			return err
+		}
+		html = exported.HTML
+		markdown = unescapeMarkdown(exported.Markdown)
+		markdown = decodeEntities(markdown)
+	}
+	if isLinkDoc(doc) {
+		markdown = linkMarkdown(doc, markdown)
+	} else if doc.URL != "" {
+		markdown = sourceLine(doc.URL) + markdown
+	}
+	if *annotateDates && doc.Created > 0 {
+		markdown = annotateRelativeDates(markdown, docTime(doc.Created))
+	}
+	markdown = skipLargeRes(ctx, wizUser, doc, markdown)
+	if *format == "anki" {
+		return exportAnki(wizUser, doc, markdown)
+	}
+	minutes := readingTime(markdown)
+	atomic.AddInt64(&readingMinutes, int64(minutes))
+	var atts []*Attachment
+	if doc.AttachmentCount > 0 {
+		var err error
+		if atts, err = fetchAttachments(ctx, wizUser, doc); err != nil {
+			return err
+		}
+		if len(atts) > 0 {
+			skipLargeAttachments(wizUser, doc, atts)
+			claimAttachments(root, doc.DocGuid, atts)
+		}
+	}
+	docPath := claimDocFile(filepath.Join(root, docFileName(doc)), doc.DocGuid)
+	assets := assetsDir(docPath)
+	cover := coverName(doc)
+	// stableHead is the front matter without the fields changing with every version of the doc
+	head, stableHead := "", ""
+	if *frontMatter || siteFormat() {
+		fields := append(docFrontMatter(wizUser, doc), fmField{"reading_time", minutes})
+		if cover != "" {
+			fields = append(fields, fmField{"cover", assets + "/" + cover})
+		}
+		head, stableHead = renderFrontMatter(fields), renderFrontMatter(stableFields(fields))
+	}
+	htmlFile := strings.TrimSuffix(docPath, ".md") + ".html"
+	resNames := docResources(markdown)
+	markdown, html, resNames = addResExts(ctx, filepath.Join(root, assets), wizUser, doc, markdown, html, resNames)
+	var externals []string
+	if *downloadExternal {
+		markdown, externals = localizeExternalImages(ctx, filepath.Join(root, assets), markdown)
+	}
+	var parts []docPart
+	if *splitByHeading > 0 {
+		if parts = splitDoc(doc.Title, markdown, *splitByHeading); len(parts) > 1 {
+			docPath = strings.TrimSuffix(docPath, ".md")
+		}
+	}
+	if len(parts) > 1 {
+		// the parts are one level below the resources of the doc
+		for i := range parts {
+			parts[i].content = rewriteAssets(parts[i].content, "../"+assets)
+		}
+	}
+	markdown = rewriteAssets(markdown, assets)
+	// the list goes after the split, the parts are one level below the attachments too
+	if len(atts) > 0 {
+		if len(parts) > 1 {
+			parts[len(parts)-1].content += attachmentList(atts, "../"+attachmentsDir)
+		} else {
+			markdown += attachmentList(atts, attachmentsDir)
+		}
+		atts = downloadedAttachments(atts)
+	}
+	// the anchors of a split doc are in other files
+	if *insertToc && len(parts) <= 1 {
+		markdown = insertTOC(markdown)
+	}
+	if *breadcrumbs {
+		if len(parts) > 1 {
+			parts[len(parts)-1].content += breadcrumb(root, 1)
+		} else {
+			markdown += breadcrumb(root, 0)
+		}
+	}
+	var htmlData []byte
+	if *format == "html" || *format == "both" {
+		htmlData = rewriteHTMLAssets(html, assets)
+	}
+	if *format == "html" {
+		// the html takes the place of the markdown
+		docPath, parts, head, stableHead, markdown = htmlFile, nil, "", "", string(htmlData)
 	}
-	markdown = strings.ReplaceAll(markdown, "\\", "")
-	if err := os.WriteFile(path.Join(root, docName), []byte(markdown), 0644); err != nil {
+	// the hash is of the doc as written, masked or not, a new version whose content is the same
+	// keeps the file
+	written, _ := maskDoc([]byte(stableHead + markdown))
+	hash := hashContent(written)
+	if *incremental && unchangedDoc(doc.DocGuid, docPath, hash) {
+		logInfof("\tunchanged: %s\n", docPath)
+	} else if len(parts) > 1 {
+		if err := writeParts(docPath, head, parts); err != nil {
+			return err
+		}
+	} else if err := writeDoc(docPath, []byte(head+markdown)); err != nil {
 		return WrapErr("WriteFile err", err)
 	}
 
-	// replace \\
-	rc, err := regexp.Compile("!\\[\\]\\(index_files/(.*?)\\)")
-	if err != nil {
-		return WrapErr("ConvertString err", err)
+	files := []string{docPath}
+	if *format == "both" {
+		if err := writeDoc(htmlFile, htmlData); err != nil {
+			return WrapErr("WriteFile html", err)
+		}
+		files = append(files, htmlFile)
+	}
+	if len(parts) > 1 {
+		files = files[:0]
+		for _, part := range parts {
+			files = append(files, filepath.Join(docPath, part.name))
+		}
+	}
+	for _, file := range files {
+		if err := setDocTimes(file, doc); err != nil {
+			logErrorf("\tset times %s err: %v\n", file, err)
+		}
+	}
+
+	entry := &ManifestEntry{
+		DocGuid:  doc.DocGuid,
+		Title:    doc.Title,
+		Category: doc.Category,
+		Path:     relPath(docPath),
+		Created:  doc.Created,
+		Keywords: parseKeywords(doc.Keywords),
+		Hash:     hash,
+	}
+	if isLinkDoc(doc) {
+		entry.Type = "link"
+	}
+	if len(parts) > 1 {
+		for _, file := range files {
+			entry.Parts = append(entry.Parts, relPath(file))
+		}
 	}
-	matchStrs := rc.FindAllStringSubmatch(markdown, -1)
+	for _, fname := range resNames {
+		entry.Resources = append(entry.Resources, relPath(filepath.Join(root, assets, fname)))
+	}
+	for _, name := range externals {
+		entry.Resources = append(entry.Resources, relPath(filepath.Join(root, assets, name)))
+	}
+	for _, att := range atts {
+		entry.Resources = append(entry.Resources, relPath(filepath.Join(root, attachmentsDir, att.file)))
+	}
+	if cover != "" {
+		entry.Resources = append(entry.Resources, relPath(filepath.Join(root, assets, cover)))
+	}
+	recordDoc(entry)
+	docEvent(entry)
 
 	// download resources
-	fmt.Printf("Resource:\n\tcount: %v\n", len(matchStrs))
-	for _, str := range matchStrs {
-		fname := str[1]
-		fmt.Printf("\tres: %s\n", fname)
-		if err := fetchRes(path.Join(root, "index_files"), wizUser, doc, fname); err != nil {
-			fmt.Println("fetchRes err:", err)
+	release()
+	logDebugf("Resource:\n\tdoc: %s\n\tcount: %v\n", doc.DocGuid, len(resNames))
+	if len(resNames) > 0 || cover != "" {
+		if err := os.MkdirAll(filepath.Join(root, assets), 0755); err != nil {
+			return WrapErr("MkdirAll assets", err)
+		}
+	}
+	var wg sync.WaitGroup
+	resSlots := make(chan struct{}, resWorkerCount())
+	for _, fname := range resNames {
+		fname := fname
+		logDebugf("\tres: %s/%s\n", doc.DocGuid, fname)
+		resPath := filepath.Join(root, assets, fname)
+		// take the slots before starting the goroutine, so that waiting resources cost none
+		resSlots <- struct{}{}
+		release := acquireTask()
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-resSlots }()
+			defer release()
+			runTask(resTask(resPath, wizUser, doc, fname))
+		}()
+	}
+	if len(atts) > 0 {
+		if err := os.MkdirAll(filepath.Join(root, attachmentsDir), 0755); err != nil {
+			return WrapErr("MkdirAll attachments", err)
 		}
-		time.Sleep(100 * time.Millisecond)
 	}
+	for _, att := range atts {
+		att := att
+		logDebugf("\tattachment: %s/%s\n", doc.DocGuid, att.file)
+		attPath := filepath.Join(root, attachmentsDir, att.file)
+		resSlots <- struct{}{}
+		release := acquireTask()
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-resSlots }()
+			defer release()
+			runTask(attachmentTask(attPath, wizUser, doc, att))
+		}()
+	}
+	if cover != "" {
+		logDebugf("\tcover: %s/%s\n", doc.DocGuid, cover)
+		coverPath := filepath.Join(root, assets, cover)
+		resSlots <- struct{}{}
+		release := acquireTask()
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-resSlots }()
+			defer release()
+			runTask(coverTask(coverPath, wizUser, doc))
+		}()
+	}
+	wg.Wait()
 	return nil
 }
 
-func fetchRes(root string, wizUser *WizUser, doc *Doc, fileName string) error {
-	resPath := path.Join(root, fileName)
-	_, err := os.Stat(resPath)
-	// skip exist file
-	if os.IsExist(err) {
+// docResources returns the resource names referenced by the markdown, once each.
+func docResources(markdown string) []string {
+	return wiz.ResourceNames(markdown)
+}
+
+func docFileName(doc *Doc) string {
+	name := doc.Title
+	if !strings.HasSuffix(name, ".md") {
+		name += ".md"
+	}
+	// jekyll posts are named 2024-01-01-title.md
+	if *format == "jekyll" && doc.Created > 0 {
+		name = docTime(doc.Created).Format("2006-01-02") + "-" + name
+	}
+	return truncateFileName(sanitizeFileName(name), docNameMax())
+}
+
+func fetchRes(ctx context.Context, resPath string, wizUser *WizUser, doc *Doc, fileName string) error {
+	// skip exist file, Stat returns no error for it
+	if _, err := os.Stat(resPath); err == nil {
+		reuseThumb(doc.DocGuid, resPath)
 		return nil
 	}
-	tmpData, err := Fetch(fmt.Sprintf("%s/ks/note/view/%s/%s/index_files/%s",
-		wizUser.KbServer, wizUser.KbGuid, doc.DocGuid, fileName), wizUser.Token)
+	resURL := resourceURL(wizUser, doc, fileName)
+	recordAsset(resPath, resURL)
+	tmpData, err := taskClient(ctx, wizUser).Resource(doc.DocGuid, fileName, *resAccept)
 	if err != nil {
-		return WrapErr("fetch res", err)
+		return err
 	}
-	if err := os.WriteFile(resPath, tmpData, 0644); err != nil {
+	if err := writeFile(resPath, tmpData); err != nil {
 		return WrapErr("WriteFile res", err)
 	}
+	resDownloaded(doc.DocGuid, resPath)
+	makeThumb(doc.DocGuid, resPath, tmpData)
 
 	return nil
 }
 
+// relPath returns the slash separated path relative to the output.
+func relPath(file string) string {
+	rel, err := filepath.Rel(exportRoot, file)
+	if err != nil {
+		return file
+	}
+	return filepath.ToSlash(rel)
+}
+
+// docTime converts the wiz timestamps in milliseconds, timestamps in seconds are accepted too.
+func docTime(ts int) time.Time {
+	if ts > 1e11 {
+		return time.Unix(0, int64(ts)*int64(time.Millisecond))
+	}
+	return time.Unix(int64(ts), 0)
+}
+
+// setDocTimes sets the modified time of the file to the doc's, its created time if unknown,
+// and the access time to the doc's last access.
+func setDocTimes(file string, doc *Doc) error {
+	modified := docModified(doc)
+	if modified <= 0 {
+		return nil
+	}
+	mtime := docTime(modified)
+	atime := mtime
+	if doc.Accessed > 0 {
+		atime = docTime(doc.Accessed)
+	}
+	return os.Chtimes(file, atime, mtime)
+}
+
 func PanicErr(err error) {
 	if err != nil {
 		panic(err)
@@ -197,61 +725,134 @@ func PanicErr(err error) {
 
 func WrapErr(errMsg string, err error) error {
 	if err != nil {
-		return errors.New(errMsg + ", err: " + err.Error())
+		return fmt.Errorf("%s, err: %w", errMsg, err)
 	}
 	return nil
 }
 
+// Login logs in on a copy of wizClient, the workers read wizClient while a refresh logs in again.
 func Login(userId, password string) (*WizUser, error) {
-	body := map[string]string{"userId": userId, "password": password}
-	bs, err := json.Marshal(body)
-	if err != nil {
-		return nil, err
+	c := wizClient.WithUser(nil)
+	user, err := c.Login(userId, password)
+	var verify *wiz.VerifyError
+	if !errors.As(err, &verify) {
+		return user, err
 	}
-	resp, err := http.Post("https://as.wiz.cn/as/user/login", "application/json", bytes.NewReader(bs))
+	code, err := verificationCode(verify)
 	if err != nil {
 		return nil, err
 	}
+	return c.LoginWithCode(userId, password, code)
+}
 
-	if resp.StatusCode != http.StatusOK {
-		return nil, errors.New(resp.Status)
-	}
-	rs, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		return nil, err
-	}
-	ur := new(WizUserResult)
-	err = json.Unmarshal(rs, ur)
-	if err != nil {
-		return nil, err
-	}
-	if ur.ReturnCode != 200 {
-		return nil, errors.New(ur.ReturnMessage)
+var (
+	logSample  = flag.Float64("log-sample", 1, "ratio of the fetch request logs printed, errors are always printed")
+	resAccept  = flag.String("res-accept", "", "Accept header of the resource requests, like image/png,image/*, to get the original format")
+	maxRetries = flag.Int("maxRetries", 3, "retries of a request failed by a network error or a 5xx status")
+	retryDelay = flag.Duration("retryDelay", 200*time.Millisecond, "delay before the first retry of a request, doubled every retry")
+)
+
+// transientErr reports whether the request may succeed once retried: network errors and 5xx, not 4xx.
+func transientErr(err error) bool {
+	var se *wiz.StatusError
+	if errors.As(err, &se) {
+		return se.Code >= 500
 	}
+	var ne net.Error
+	return errors.As(err, &ne)
+}
 
-	return ur.Result, nil
+// FetchRes fetches a resource with the --res-accept header.
+func FetchRes(ctx context.Context, url, token string) ([]byte, error) {
+	return fetchAccept(ctx, url, token, *resAccept)
 }
 
-func Fetch(url, token string) ([]byte, error) {
-	fmt.Println("\tfetch:", url)
-	req, err := http.NewRequest(http.MethodGet, url, nil)
-	if err != nil {
-		return nil, err
-	}
-	req.Header.Set("X-Wiz-Token", token)
+func fetchAccept(ctx context.Context, url, token, accept string) ([]byte, error) {
+	return retryRequest(ctx, url, token, func(token string) ([]byte, error) {
+		return getChecked(ctx, url, token, accept)
+	})
+}
 
-	resp, err := http.DefaultClient.Do(req)
-	if err != nil {
-		return nil, err
+// retryRequest sends the request by do with the token until it succeeds: paced, paused while
+// rate limited, the token refreshed once if unauthorized and the transient errors retried.
+func retryRequest(ctx context.Context, url, token string, do func(token string) ([]byte, error)) ([]byte, error) {
+	waited, refreshed := false, false
+	// the transient errors and the rate limits retried so far, counted apart
+	retries, risks := 0, 0
+	for {
+		waitIfPaused()
+		if err := requestPacer.Wait(ctx); err != nil {
+			return nil, err
+		}
+		if *logSample >= 1 || rand.Float64() < *logSample {
+			logDebugf("\tfetch: %v\n", url)
+		}
+		rs, err := do(token)
+		if ctx.Err() != nil {
+			// canceled, not worth a retry
+			return nil, ctx.Err()
+		}
+		var risk *riskError
+		if errors.As(err, &risk) && risks < *riskRetries {
+			if risk.code == http.StatusTooManyRequests && risk.retryAfter == 0 {
+				// a 429 without Retry-After backs off the request only, like the transient errors
+				delay := *retryDelay << uint(risks)
+				risks++
+				logInfof("\tretry rate limit %d/%d in %s: %s\n", risks, *riskRetries, delay, url)
+				select {
+				case <-time.After(delay):
+				case <-ctx.Done():
+				}
+				continue
+			}
+			waitRisk(risk, risks)
+			risks++
+			continue
+		}
+		// requests without the token, like the external images, never get it
+		if unauthorized(err) && !refreshed && tokenUser != nil && token != "" {
+			refreshed = true
+			if token, err = refreshToken(token); err == nil {
+				continue
+			}
+		}
+		if transientErr(err) && retries < *maxRetries {
+			delay := *retryDelay << uint(retries)
+			retries++
+			logInfof("\tretry %d/%d in %s: %s, err: %v\n", retries, *maxRetries, delay, url, err)
+			select {
+			case <-time.After(delay):
+			case <-ctx.Done():
+			}
+			continue
+		}
+		if netErrorsReached(err) && !waited {
+			waitNetwork()
+			waited = true
+			continue
+		}
+		if err != nil {
+			logErrorf("\tfetch err: %v %v\n", url, err)
+		}
+		return rs, err
 	}
+}
 
-	if resp.StatusCode != http.StatusOK {
-		return nil, errors.New(resp.Status)
-	}
-	rs, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
+// getChecked gets the url by the wiz client, the rate limits of wiz are returned as *riskError.
+func getChecked(ctx context.Context, url, token, accept string) ([]byte, error) {
+	rs, err := wizClient.Get(ctx, url, token, accept)
+	status, body := http.StatusOK, rs
+	var se *wiz.StatusError
+	if errors.As(err, &se) {
+		status, body = se.Code, se.Body
+	} else if err != nil {
 		return nil, err
 	}
-
-	return rs, nil
+	if risk := checkRisk(status, body); risk != nil {
+		if se != nil {
+			risk.retryAfter = retryAfter(se.Header.Get("Retry-After"))
+		}
+		return nil, risk
+	}
+	return rs, err
 }
@@ -0,0 +1,248 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/GalaIO/wiz_export/wiz"
+)
+
+// fakeWiz is a kb server with the docs of its folders, counting the requests of every path.
+type fakeWiz struct {
+	*httptest.Server
+	folders map[string][]*Doc
+	// html of the docs, the docs without one fail with 500
+	html        map[string]string
+	attachments map[string][]*wiz.Attachment
+	// onRequest is called before every request is served, if set
+	onRequest func(r *http.Request)
+
+	mu       sync.Mutex
+	requests map[string]int
+}
+
+func newFakeWiz(t *testing.T) *fakeWiz {
+	f := &fakeWiz{folders: make(map[string][]*Doc), html: make(map[string]string),
+		attachments: make(map[string][]*wiz.Attachment), requests: make(map[string]int)}
+	f.Server = httptest.NewServer(http.HandlerFunc(f.serve))
+	t.Cleanup(f.Close)
+	return f
+}
+
+func (f *fakeWiz) addDoc(doc *Doc, html string) {
+	if doc.Type == "" {
+		doc.Type = "document"
+	}
+	f.folders[doc.Category] = append(f.folders[doc.Category], doc)
+	if html != "" {
+		f.html[doc.DocGuid] = html
+	}
+}
+
+func (f *fakeWiz) count(path string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.requests[path]
+}
+
+func (f *fakeWiz) serve(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	f.requests[r.URL.Path]++
+	f.mu.Unlock()
+	if f.onRequest != nil {
+		f.onRequest(r)
+	}
+	if r.Header.Get("X-Wiz-Token") != "t1" {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	const view = "/ks/note/view/kb1/"
+	switch {
+	case r.URL.Path == "/ks/note/list/category/kb1":
+		result := &DocListResult{ResultCode: ResultCode{ReturnCode: 200}, Result: []*Doc{}}
+		if r.URL.Query().Get("start") == "0" {
+			result.Result = append(result.Result, f.folders[r.URL.Query().Get("category")]...)
+		}
+		json.NewEncoder(w).Encode(result)
+	case strings.HasPrefix(r.URL.Path, "/ks/note/attachments/kb1/"):
+		json.NewEncoder(w).Encode(&wiz.AttachmentListResult{ResultCode: ResultCode{ReturnCode: 200},
+			Result: f.attachments[strings.TrimPrefix(r.URL.Path, "/ks/note/attachments/kb1/")]})
+	case strings.HasPrefix(r.URL.Path, "/ks/attachment/download/kb1/"):
+		fmt.Fprintf(w, "attachment %s", strings.TrimPrefix(r.URL.Path, "/ks/attachment/download/kb1/"))
+	case strings.Contains(r.URL.Path, "/index_files/"):
+		w.Write([]byte("\x89PNG\r\n\x1a\n" + r.URL.Path))
+	case strings.HasPrefix(r.URL.Path, view):
+		html, ok := f.html[strings.TrimPrefix(r.URL.Path, view)]
+		if !ok {
+			http.Error(w, "server error", http.StatusInternalServerError)
+			return
+		}
+		w.Write([]byte(html))
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// user returns the login of the kb kb1 on the server.
+func (f *fakeWiz) user() *WizUser {
+	return &WizUser{KbServer: f.URL, KbGuid: "kb1", Token: "t1"}
+}
+
+// setFlags sets the flags for the test, restored after it.
+func setFlags(t *testing.T, values map[string]string) {
+	for name, value := range values {
+		old := flag.Lookup(name).Value.String()
+		if err := flag.Set(name, value); err != nil {
+			t.Fatalf("set flag %s: %v", name, err)
+		}
+		name := name
+		t.Cleanup(func() { flag.Set(name, old) })
+	}
+}
+
+// setupExport points the export to a temp output and the fake server, without delays and retries.
+func setupExport(t *testing.T, f *fakeWiz, values map[string]string) string {
+	out := t.TempDir()
+	flags := map[string]string{
+		"output":      out,
+		"quiet":       "true",
+		"interval":    "0",
+		"maxRetries":  "0",
+		"retryRounds": "0",
+	}
+	for name, value := range values {
+		flags[name] = value
+	}
+	setFlags(t, flags)
+	requestPacer.SetInterval(0)
+	if err := setupClient(); err != nil {
+		t.Fatalf("setupClient: %v", err)
+	}
+	setupWizClient()
+	wizClient.HTTPClient = f.Client()
+	httpClient = f.Client()
+	resetKb()
+	t.Cleanup(resetKb)
+	return out
+}
+
+func readFile(t *testing.T, file string) string {
+	bs, err := ioutil.ReadFile(file)
+	if err != nil {
+		t.Fatalf("ReadFile: %v", err)
+	}
+	return string(bs)
+}
+
+func TestExportKb(t *testing.T) {
+	f := newFakeWiz(t)
+	f.addDoc(&Doc{DocGuid: "doc1", Title: "Doc One", Category: "/a/"},
+		`<h1>One</h1><p>first</p><img src="index_files/x.png"><img src="index_files/x.png">`)
+	f.addDoc(&Doc{DocGuid: "doc2", Title: "Doc Two", Category: "/a/"}, `<p>second</p>`)
+	out := setupExport(t, f, map[string]string{"folders": "/a/"})
+
+	status := exportKb(f.user(), time.Now())
+	if status == nil || !status.Success {
+		t.Fatalf("exportKb status = %+v", status)
+	}
+	if status.Counts.Exported != 2 || status.Counts.Resources != 1 || status.Counts.Failed != 0 {
+		t.Errorf("exportKb counts = %+v", status.Counts)
+	}
+	md := readFile(t, filepath.Join(out, "a", "Doc One.md"))
+	if !strings.Contains(md, "first") || !strings.Contains(md, "Doc One.assets/x.png") {
+		t.Errorf("Doc One.md = %q", md)
+	}
+	if md := readFile(t, filepath.Join(out, "a", "Doc Two.md")); !strings.Contains(md, "second") {
+		t.Errorf("Doc Two.md = %q", md)
+	}
+	if _, err := os.Stat(filepath.Join(out, "a", "Doc One.assets", "x.png")); err != nil {
+		t.Errorf("res not written: %v", err)
+	}
+	const res = "/ks/note/view/kb1/doc1/index_files/x.png"
+	if n := f.count(res); n != 1 {
+		t.Errorf("res downloaded %d times, want once", n)
+	}
+
+	// the resources downloaded before are skipped
+	resetKb()
+	status = exportKb(f.user(), time.Now())
+	if status == nil || status.Counts.Exported != 2 || status.Counts.Resources != 0 {
+		t.Errorf("second exportKb status = %+v", status)
+	}
+	if n := f.count(res); n != 1 {
+		t.Errorf("res downloaded %d times after the second export, want once", n)
+	}
+}
+
+func TestExportKbFailedDoc(t *testing.T) {
+	f := newFakeWiz(t)
+	f.addDoc(&Doc{DocGuid: "doc1", Title: "Good", Category: "/a/"}, `<p>good</p>`)
+	f.addDoc(&Doc{DocGuid: "doc2", Title: "Bad", Category: "/a/"}, "")
+	out := setupExport(t, f, map[string]string{"folders": "/a/"})
+
+	status := exportKb(f.user(), time.Now())
+	if status == nil || status.Success {
+		t.Fatalf("exportKb status = %+v, want a failure", status)
+	}
+	if status.Counts.Exported != 1 || status.Counts.Failed != 1 {
+		t.Errorf("exportKb counts = %+v", status.Counts)
+	}
+	if _, err := os.Stat(filepath.Join(out, "a", "Good.md")); err != nil {
+		t.Errorf("Good.md not written: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(out, "a", "Bad.md")); !os.IsNotExist(err) {
+		t.Errorf("Bad.md written, err: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(out, failedDocsFile)); err != nil {
+		t.Errorf("%s not written: %v", failedDocsFile, err)
+	}
+}
+
+func TestFetchRes(t *testing.T) {
+	f := newFakeWiz(t)
+	out := setupExport(t, f, nil)
+	exportRoot = out
+	doc := &Doc{DocGuid: "doc1"}
+	tests := []struct {
+		name     string
+		existing string
+		want     string
+		requests int
+	}{
+		{"existing", "kept", "kept", 0},
+		{"missing", "", "\x89PNG\r\n\x1a\n/ks/note/view/kb1/doc1/index_files/missing.png", 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fname := tt.name + ".png"
+			resPath := filepath.Join(out, "assets", fname)
+			if err := os.MkdirAll(filepath.Dir(resPath), 0755); err != nil {
+				t.Fatal(err)
+			}
+			if tt.existing != "" {
+				if err := ioutil.WriteFile(resPath, []byte(tt.existing), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := fetchRes(exportCtx, resPath, f.user(), doc, fname); err != nil {
+				t.Fatalf("fetchRes: %v", err)
+			}
+			if got := readFile(t, resPath); got != tt.want {
+				t.Errorf("res = %q, want %q", got, tt.want)
+			}
+			if n := f.count("/ks/note/view/kb1/doc1/index_files/" + fname); n != tt.requests {
+				t.Errorf("res requested %d times, want %d", n, tt.requests)
+			}
+		})
+	}
+}
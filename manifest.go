@@ -0,0 +1,228 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	manifestFile   = "manifest.json"
+	diffReportFile = "diff_report.md"
+)
+
+type Manifest struct {
+	Generated string           `json:"generated"`
+	Docs      []*ManifestEntry `json:"docs"`
+}
+
+type ManifestEntry struct {
+	DocGuid  string `json:"docGuid"`
+	Title    string `json:"title"`
+	Category string `json:"category"`
+	// Path is relative to the output, slash separated
+	Path     string   `json:"path"`
+	Created  int      `json:"created"`
+	Keywords []string `json:"keywords,omitempty"`
+	Type     string   `json:"type,omitempty"`
+	Hash     string   `json:"hash"`
+	// SourceHash is the hash of the doc before its wiz links were rewritten and without its volatile
+	// front matter, compared by --incremental
+	SourceHash string   `json:"sourceHash,omitempty"`
+	Resources  []string `json:"resources,omitempty"`
+	// Parts are the files of a doc split by headings, Path is their folder then
+	Parts []string `json:"parts,omitempty"`
+	// Thumbs maps the image resources to their thumbnails
+	Thumbs map[string]string `json:"thumbs,omitempty"`
+}
+
+type ManifestDiff struct {
+	Added    []*ManifestEntry
+	Modified []*ManifestEntry
+	Removed  []*ManifestEntry
+}
+
+var (
+	incremental = flag.Bool("incremental", false, "skip the docs not modified in wiz since the last export, and keep the doc files whose content hash is unchanged")
+
+	manifestMu   sync.Mutex
+	prevManifest = new(Manifest)
+	prevDocs     = make(map[string]*ManifestEntry)
+	// docs exported, listed and folders listed in this run
+	exportedDocs  = make(map[string]*ManifestEntry)
+	listedDocs    = make(map[string]bool)
+	listedFolders = make(map[string]bool)
+)
+
+func loadManifest(file string) (*Manifest, error) {
+	bs, err := ioutil.ReadFile(file)
+	if os.IsNotExist(err) {
+		return new(Manifest), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	m := new(Manifest)
+	if err := json.Unmarshal(bs, m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func setPrevManifest(m *Manifest) {
+	manifestMu.Lock()
+	defer manifestMu.Unlock()
+	prevManifest = m
+	prevDocs = make(map[string]*ManifestEntry, len(m.Docs))
+	for _, entry := range m.Docs {
+		prevDocs[entry.DocGuid] = entry
+	}
+}
+
+// unchangedDoc reports whether the doc file exported last time has the same path and content hash.
+func unchangedDoc(docGuid, file, hash string) bool {
+	manifestMu.Lock()
+	prev, ok := prevDocs[docGuid]
+	manifestMu.Unlock()
+	if !ok {
+		return false
+	}
+	prevHash := prev.Hash
+	if prev.SourceHash != "" {
+		prevHash = prev.SourceHash
+	}
+	if prevHash != hash || prev.Path != relPath(file) {
+		return false
+	}
+	_, err := os.Stat(file)
+	return err == nil
+}
+
+func hashContent(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// recordListed marks the folder and its docs as seen, docs of a listed folder missing
+// from the listing are reported as removed.
+func recordListed(folder string, docs []*Doc) {
+	manifestMu.Lock()
+	defer manifestMu.Unlock()
+	listedFolders[folder] = true
+	for _, doc := range docs {
+		listedDocs[doc.DocGuid] = true
+	}
+}
+
+// recordDoc records the exported doc, it returns false if the doc was recorded before in the kb.
+func recordDoc(entry *ManifestEntry) bool {
+	manifestMu.Lock()
+	defer manifestMu.Unlock()
+	carryThumbs(entry)
+	_, ok := exportedDocs[entry.DocGuid]
+	exportedDocs[entry.DocGuid] = entry
+	return !ok
+}
+
+// buildManifest merges the docs exported in this run into the previous manifest,
+// entries of folders not exported and docs failed to export are carried over.
+func buildManifest() *Manifest {
+	manifestMu.Lock()
+	defer manifestMu.Unlock()
+	m := &Manifest{Generated: time.Now().Format(time.RFC3339)}
+	for _, entry := range prevManifest.Docs {
+		if _, ok := exportedDocs[entry.DocGuid]; ok {
+			continue
+		}
+		if listedFolders[entry.Category] && !listedDocs[entry.DocGuid] {
+			continue
+		}
+		m.Docs = append(m.Docs, entry)
+	}
+	for _, entry := range exportedDocs {
+		m.Docs = append(m.Docs, entry)
+	}
+	sort.Slice(m.Docs, func(i, j int) bool {
+		return m.Docs[i].Path < m.Docs[j].Path
+	})
+	return m
+}
+
+type diffSection struct {
+	name    string
+	entries []*ManifestEntry
+}
+
+func (d *ManifestDiff) sections() []diffSection {
+	return []diffSection{{"Added", d.Added}, {"Modified", d.Modified}, {"Removed", d.Removed}}
+}
+
+func diffManifests(prev, cur *Manifest) *ManifestDiff {
+	prevDocs := make(map[string]*ManifestEntry, len(prev.Docs))
+	for _, entry := range prev.Docs {
+		prevDocs[entry.DocGuid] = entry
+	}
+	diff := new(ManifestDiff)
+	for _, entry := range cur.Docs {
+		old, ok := prevDocs[entry.DocGuid]
+		delete(prevDocs, entry.DocGuid)
+		if !ok {
+			diff.Added = append(diff.Added, entry)
+		} else if old.Hash != entry.Hash || old.Path != entry.Path {
+			diff.Modified = append(diff.Modified, entry)
+		}
+	}
+	for _, entry := range prev.Docs {
+		if _, ok := prevDocs[entry.DocGuid]; ok {
+			diff.Removed = append(diff.Removed, entry)
+		}
+	}
+	return diff
+}
+
+// saveManifest writes the manifest of this run and the diff report against the previous one.
+func saveManifest(root string) (*ManifestDiff, error) {
+	m := buildManifest()
+	bs, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return nil, WrapErr("Marshal manifest", err)
+	}
+	if err := atomicWriteFile(filepath.Join(root, manifestFile), bs, 0644); err != nil {
+		return nil, WrapErr("WriteFile manifest", err)
+	}
+
+	diff := diffManifests(prevManifest, m)
+	logInfof("Diff info:\n\tadded: %v\n\tmodified: %v\n\tremoved: %v\n",
+		len(diff.Added), len(diff.Modified), len(diff.Removed))
+	for _, sec := range diff.sections() {
+		for _, entry := range sec.entries {
+			logInfof("\t%s: %s %s\n", sec.name, entry.DocGuid, entry.Path)
+		}
+	}
+	report := diffReport(prevManifest, m, diff)
+	if err := atomicWriteFile(filepath.Join(root, diffReportFile), report, 0644); err != nil {
+		return nil, WrapErr("WriteFile diff report", err)
+	}
+	return diff, nil
+}
+
+func diffReport(prev, cur *Manifest, diff *ManifestDiff) []byte {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "# Export diff\n\n- previous: %s\n- current: %s\n", prev.Generated, cur.Generated)
+	for _, sec := range diff.sections() {
+		fmt.Fprintf(&buf, "\n## %s (%d)\n\n", sec.name, len(sec.entries))
+		for _, entry := range sec.entries {
+			fmt.Fprintf(&buf, "- [%s](<%s>) `%s`\n", entry.Title, entry.Path, entry.DocGuid)
+		}
+	}
+	return buf.Bytes()
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestIncrementalVersionBump(t *testing.T) {
+	f := newFakeWiz(t)
+	modified := time.Now().Add(-2 * time.Hour)
+	doc := &Doc{DocGuid: "doc1", Title: "Doc", Category: "/a/", Modified: int(modified.Unix())}
+	f.addDoc(doc, "<p>body</p>")
+	out := setupExport(t, f, map[string]string{"folders": "/a/", "incremental": "true", "frontMatter": "true"})
+	file := filepath.Join(out, "a", "Doc.md")
+
+	export := func() {
+		resetKb()
+		if status := exportKb(f.user(), time.Now()); status == nil || !status.Success {
+			t.Fatalf("exportKb status = %+v", status)
+		}
+	}
+	export()
+	first := readFile(t, file)
+	if !strings.Contains(first, `updated: "`+docTime(doc.Modified).Format(time.RFC3339)) {
+		t.Fatalf("Doc.md without the updated time: %q", first)
+	}
+
+	// a new version of the same content keeps the file
+	doc.Modified = int(time.Now().Add(time.Hour).Unix())
+	export()
+	if got := readFile(t, file); got != first {
+		t.Errorf("Doc.md rewritten for a new version of the same content: %q", got)
+	}
+
+	// the changed content is written with its updated time
+	f.html["doc1"] = "<p>new body</p>"
+	doc.Modified = int(time.Now().Add(2 * time.Hour).Unix())
+	export()
+	got := readFile(t, file)
+	if !strings.Contains(got, "new body") || !strings.Contains(got, `updated: "`+docTime(doc.Modified).Format(time.RFC3339)) {
+		t.Errorf("Doc.md not rewritten for the changed content: %q", got)
+	}
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+
+	md "github.com/JohannesKaufmann/html-to-markdown"
+)
+
+var (
+	bulletListMarker = flag.String("bulletListMarker", "-", "marker of the list items: -, + or *")
+	headingStyle     = flag.String("headingStyle", "atx", "style of the headings: atx (# title) or setext (title underlined)")
+	emDelimiter      = flag.String("emDelimiter", "_", "delimiter of the emphasis: _ or *")
+	strongDelimiter  = flag.String("strongDelimiter", "**", "delimiter of the strong emphasis: ** or __")
+	horizontalRule   = flag.String("horizontalRule", "* * *", "thematic break of the <hr>, like --- or * * *")
+)
+
+// converterOptions returns the markdown options of the flags, the defaults are those of the converter.
+func converterOptions() (*md.Options, error) {
+	checks := []struct {
+		name, value string
+		allowed     []string
+	}{
+		{"bulletListMarker", *bulletListMarker, []string{"-", "+", "*"}},
+		{"headingStyle", *headingStyle, []string{"atx", "setext"}},
+		{"emDelimiter", *emDelimiter, []string{"_", "*"}},
+		{"strongDelimiter", *strongDelimiter, []string{"**", "__"}},
+	}
+	for _, c := range checks {
+		if !containsString(c.allowed, c.value) {
+			return nil, fmt.Errorf("unknown %s: %s, one of %v", c.name, c.value, c.allowed)
+		}
+	}
+	return &md.Options{
+		BulletListMarker: *bulletListMarker,
+		HeadingStyle:     *headingStyle,
+		EmDelimiter:      *emDelimiter,
+		StrongDelimiter:  *strongDelimiter,
+		HorizontalRule:   *horizontalRule,
+	}, nil
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+var merge = flag.Bool("merge", false, "merge the docs of every folder by created time into <folder>.md, a section per doc, the resources in <folder>.assets/<docGuid>/")
+
+// mergedPath returns the merged markdown of the folder, like 日记/2023.md for /日记/2023/.
+func mergedPath(root, folder string) string {
+	dir := folderPath(root, folder)
+	if dir == filepath.Clean(root) {
+		return filepath.Join(root, "index.md")
+	}
+	return dir + ".md"
+}
+
+// mergeFolder exports the docs of the folder into one markdown, ordered by the created time.
+// The docs failed to export are left out and the folder is retried with them.
+func mergeFolder(root string, wizUser *WizUser, folder string) error {
+	list, err := cachedFolderDocs(wizUser, folder)
+	if err != nil {
+		checkFolderDocs(wizUser, folder, 0, 0, err)
+		return err
+	}
+	recordListed(folder, list)
+	docs := filterDocs(list)
+	checkFolderDocs(wizUser, folder, len(list), len(docs), nil)
+	sort.SliceStable(docs, func(i, j int) bool {
+		return docs[i].Created < docs[j].Created
+	})
+	atomic.AddInt64(&docsQueued, int64(len(docs)))
+
+	mergePath := mergedPath(root, folder)
+	if err := os.MkdirAll(filepath.Dir(mergePath), 0755); err != nil {
+		return WrapErr("MkdirAll folder", err)
+	}
+	assets := assetsDir(mergePath)
+	var buf strings.Builder
+	fmt.Fprintf(&buf, "# %s\n", strings.TrimSuffix(filepath.Base(mergePath), ".md"))
+	var entries []*ManifestEntry
+	var failed []string
+	for _, doc := range docs {
+		if exportStopped() {
+			break
+		}
+		logInfof("Doc info: %s\n\tdocGuid: %s\n\ttitle: %s\n", progress(), doc.DocGuid, doc.Title)
+		exported, err := kbClient(wizUser).ExportDoc(doc)
+		if err != nil {
+			logErrorf("\tdoc %s %s err: %v\n", doc.DocGuid, doc.Title, err)
+			failed = append(failed, doc.DocGuid)
+			continue
+		}
+		markdown := decodeEntities(unescapeMarkdown(exported.Markdown))
+		resNames := docResources(markdown)
+		// the resources of the docs may share names, each doc has its own folder
+		dir := assets + "/" + doc.DocGuid
+		markdown = rewriteAssets(markdown, dir)
+		resDir := filepath.Join(filepath.Dir(mergePath), dir)
+		if err := fetchMergedRes(resDir, wizUser, doc, resNames); err != nil {
+			logErrorf("\tdoc %s %s err: %v\n", doc.DocGuid, doc.Title, err)
+			failed = append(failed, doc.DocGuid)
+			continue
+		}
+		if len(entries) > 0 {
+			buf.WriteString("\n---\n")
+		}
+		fmt.Fprintf(&buf, "\n## %s\n\n%s\n", doc.Title, strings.TrimSpace(markdown))
+
+		entry := &ManifestEntry{
+			DocGuid:  doc.DocGuid,
+			Title:    doc.Title,
+			Category: doc.Category,
+			Path:     relPath(mergePath),
+			Created:  doc.Created,
+			Keywords: parseKeywords(doc.Keywords),
+		}
+		for _, fname := range resNames {
+			entry.Resources = append(entry.Resources, relPath(filepath.Join(resDir, fname)))
+		}
+		entries = append(entries, entry)
+	}
+	if err := writeDoc(mergePath, []byte(buf.String())); err != nil {
+		return WrapErr("WriteFile merged", err)
+	}
+	// a retry of the folder merges the docs exported before again, they are counted once
+	for _, entry := range entries {
+		if recordDoc(entry) {
+			atomic.AddInt64(&docsExported, 1)
+		}
+		docEvent(entry)
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("merge %d of %d docs failed: %s", len(failed), len(docs), strings.Join(failed, ", "))
+	}
+	return nil
+}
+
+// fetchMergedRes downloads the resources of a merged doc into dir.
+func fetchMergedRes(dir string, wizUser *WizUser, doc *Doc, resNames []string) error {
+	if len(resNames) == 0 {
+		return nil
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return WrapErr("MkdirAll assets", err)
+	}
+	var wg sync.WaitGroup
+	resSlots := make(chan struct{}, resWorkerCount())
+	for _, fname := range resNames {
+		fname := fname
+		logDebugf("\tres: %s/%s\n", doc.DocGuid, fname)
+		resPath := filepath.Join(dir, fname)
+		resSlots <- struct{}{}
+		release := acquireTask()
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-resSlots }()
+			defer release()
+			runTask(resTask(resPath, wizUser, doc, fname))
+		}()
+	}
+	wg.Wait()
+	return nil
+}
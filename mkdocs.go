@@ -0,0 +1,102 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const (
+	mkdocsFile = "mkdocs.yml"
+	mkdocsDocs = "docs"
+)
+
+type navNode struct {
+	name string
+	// file is relative to the docs folder, empty for folders
+	file     string
+	children []*navNode
+}
+
+func (n *navNode) child(name string) *navNode {
+	for _, c := range n.children {
+		if c.name == name && c.file == "" {
+			return c
+		}
+	}
+	c := &navNode{name: name}
+	n.children = append(n.children, c)
+	return c
+}
+
+// writeMkdocs writes mkdocs.yml with the nav built from the folders and the doc titles under docs/.
+func writeMkdocs(root string, wizUser *WizUser) error {
+	titles := make(map[string]string)
+	for _, entry := range buildManifest().Docs {
+		titles[entry.Path] = entry.Title
+	}
+
+	docsDir := filepath.Join(root, mkdocsDocs)
+	nav := new(navNode)
+	err := filepath.Walk(docsDir, func(file string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() && (strings.HasSuffix(info.Name(), assetsSuffix) || info.Name() == ankiMedia) {
+			return filepath.SkipDir
+		}
+		if info.IsDir() || !strings.HasSuffix(file, ".md") {
+			return nil
+		}
+		rel, err := filepath.Rel(docsDir, file)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		node := nav
+		dirs := strings.Split(rel, "/")
+		for _, dir := range dirs[:len(dirs)-1] {
+			node = node.child(dir)
+		}
+		title := titles[relPath(file)]
+		if title == "" {
+			title = strings.TrimSuffix(info.Name(), ".md")
+		}
+		node.children = append(node.children, &navNode{name: title, file: rel})
+		return nil
+	})
+	if err != nil {
+		return WrapErr("walk docs", err)
+	}
+
+	siteName := wizUser.DisplayName
+	if siteName == "" {
+		siteName = "WizNote"
+	}
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "site_name: %s\ndocs_dir: %s\nnav:\n", yamlString(siteName), mkdocsDocs)
+	writeNav(&buf, nav.children, 1)
+	if err := atomicWriteFile(filepath.Join(root, mkdocsFile), buf.Bytes(), 0644); err != nil {
+		return WrapErr("WriteFile mkdocs", err)
+	}
+	return nil
+}
+
+// writeNav writes the nodes as mkdocs nav items, docs before sub folders.
+func writeNav(buf *bytes.Buffer, nodes []*navNode, depth int) {
+	sort.SliceStable(nodes, func(i, j int) bool {
+		return nodes[i].file != "" && nodes[j].file == ""
+	})
+	indent := strings.Repeat("    ", depth-1)
+	for _, n := range nodes {
+		if n.file != "" {
+			fmt.Fprintf(buf, "%s  - %s: %s\n", indent, yamlString(n.name), yamlString(n.file))
+			continue
+		}
+		fmt.Fprintf(buf, "%s  - %s:\n", indent, yamlString(n.name))
+		writeNav(buf, n.children, depth+1)
+	}
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"net"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	netErrors = flag.Int("netErrors", 3, "consecutive network errors before waiting for the network to come back, 0 means off")
+	netProbe  = flag.Duration("netProbe", 30*time.Second, "interval to probe the kbServer while waiting for the network")
+
+	netErrCount int32
+	netMu       sync.Mutex
+	// the server probed while waiting, the kbServer once logged in
+	probeServer string
+)
+
+// netErrorsReached counts the consecutive network errors, other results reset the count.
+func netErrorsReached(err error) bool {
+	var ne net.Error
+	if err == nil || !errors.As(err, &ne) {
+		atomic.StoreInt32(&netErrCount, 0)
+		return false
+	}
+	n := atomic.AddInt32(&netErrCount, 1)
+	return *netErrors > 0 && probeServer != "" && int(n) >= *netErrors
+}
+
+// waitNetwork blocks until the kbServer is reachable again, only one goroutine probes.
+func waitNetwork() {
+	netMu.Lock()
+	defer netMu.Unlock()
+	if int(atomic.LoadInt32(&netErrCount)) < *netErrors {
+		// recovered while waiting for the lock
+		return
+	}
+	logInfof("Network info:\n\tdown, waiting for %s\n", probeServer)
+	for {
+		err := probe(probeServer)
+		if err == nil {
+			atomic.StoreInt32(&netErrCount, 0)
+			logInfof("\tnetwork is back\n")
+			return
+		}
+		logInfof("\tprobe err: %v, next in %s\n", err, *netProbe)
+		select {
+		case <-time.After(*netProbe):
+		case <-exportCtx.Done():
+			return
+		}
+	}
+}
+
+// probe sends a HEAD request to the server through httpClient, so through --proxy too, any
+// response means the network is back.
+func probe(server string) error {
+	ctx, cancel := context.WithTimeout(exportCtx, 10*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, server, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"sync"
+)
+
+var (
+	pauseMu   sync.Mutex
+	pauseCond = sync.NewCond(&pauseMu)
+	paused    bool
+)
+
+// setPaused holds back or resumes all downloads, the login session and progress are kept.
+func setPaused(p bool) {
+	pauseMu.Lock()
+	defer pauseMu.Unlock()
+	if paused == p {
+		return
+	}
+	paused = p
+	logInfof("Pause info:\n\tpaused: %v\n", paused)
+	if !paused {
+		pauseCond.Broadcast()
+	}
+}
+
+func togglePause() {
+	pauseMu.Lock()
+	p := !paused
+	pauseMu.Unlock()
+	setPaused(p)
+}
+
+func waitIfPaused() {
+	pauseMu.Lock()
+	defer pauseMu.Unlock()
+	for paused {
+		pauseCond.Wait()
+	}
+}
@@ -0,0 +1,7 @@
+//go:build windows
+// +build windows
+
+package main
+
+// watchPauseSignal does nothing as windows has no SIGUSR1, pause with the control file instead.
+func watchPauseSignal() {}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"sync/atomic"
+	"unicode"
+)
+
+const (
+	// reading speed per minute
+	cjkPerMinute   = 300
+	wordsPerMinute = 200
+)
+
+var readingMinutes int64
+
+// readingTime estimates the minutes to read the markdown, counting CJK characters
+// and other words, code blocks excluded.
+func readingTime(markdown string) int {
+	cjk, words := 0, 0
+	for _, line := range markdownLines(markdown) {
+		if line.code {
+			continue
+		}
+		inWord := false
+		for _, r := range line.text {
+			switch {
+			case unicode.Is(unicode.Han, r) || unicode.Is(unicode.Hiragana, r) ||
+				unicode.Is(unicode.Katakana, r) || unicode.Is(unicode.Hangul, r):
+				cjk++
+				inWord = false
+			case unicode.IsLetter(r) || unicode.IsDigit(r):
+				if !inWord {
+					words++
+				}
+				inWord = true
+			default:
+				inWord = false
+			}
+		}
+	}
+	// round up, a non empty doc takes at least a minute
+	units := cjk*wordsPerMinute + words*cjkPerMinute
+	return (units + cjkPerMinute*wordsPerMinute - 1) / (cjkPerMinute * wordsPerMinute)
+}
+
+func printReadingInfo() {
+	logInfof("Reading info:\n\ttotal: %v min\n", atomic.LoadInt64(&readingMinutes))
+}
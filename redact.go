@@ -0,0 +1,71 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+var (
+	redact       = flag.Bool("redact", false, "mask phone numbers, emails and id card numbers in the docs")
+	redactOutput = flag.String("redactOutput", "", "with --redact, keep the original export and write the masked copy into this folder")
+
+	redactRules = []struct {
+		re   *regexp.Regexp
+		mask func(string) string
+	}{
+		// id card numbers before phones, they may contain one
+		{regexp.MustCompile(`\b\d{17}[\dXx]\b`), func(s string) string { return s[:6] + strings.Repeat("*", 8) + s[14:] }},
+		{regexp.MustCompile(`\b1[3-9]\d{9}\b`), func(s string) string { return s[:3] + "****" + s[7:] }},
+		{regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`), func(s string) string {
+			at := strings.Index(s, "@")
+			return s[:1] + "***" + s[at:]
+		}},
+	}
+)
+
+// redactText masks the sensitive patterns of the text.
+func redactText(text string) string {
+	for _, rule := range redactRules {
+		text = rule.re.ReplaceAllStringFunc(text, rule.mask)
+	}
+	return text
+}
+
+// redactInPlace reports whether the docs are masked in the export itself.
+func redactInPlace() bool {
+	return *redact && *redactOutput == ""
+}
+
+// maskDoc returns the data of a doc to write into the export and into the copy under
+// --redactOutput, masked under --redact. The markdown, html and anki cards all go through it.
+func maskDoc(data []byte) (exported, copied []byte) {
+	if !*redact {
+		return data, data
+	}
+	masked := []byte(redactText(string(data)))
+	if redactInPlace() {
+		return masked, masked
+	}
+	return data, masked
+}
+
+// writeDoc writes a doc file like writeFile, masked by maskDoc.
+func writeDoc(file string, data []byte) error {
+	exported, copied := maskDoc(data)
+	return writeFileCopy(file, exported, copied)
+}
+
+// writeRedacted writes the copy of an exported file under --redactOutput.
+func writeRedacted(file string, data []byte) error {
+	if !*redact || *redactOutput == "" {
+		return nil
+	}
+	dst := filepath.Join(*redactOutput, filepath.FromSlash(relPath(file)))
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return WrapErr("MkdirAll redact", err)
+	}
+	return atomicWriteFile(dst, data, 0644)
+}
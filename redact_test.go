@@ -0,0 +1,83 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRedactText(t *testing.T) {
+	tests := []struct {
+		text string
+		want string
+	}{
+		{"no secrets", "no secrets"},
+		{"call 13812345678 now", "call 138****5678 now"},
+		{"tel:13812345678", "tel:138****5678"},
+		{"12345678901 is no phone", "12345678901 is no phone"},
+		{"mail alice.b@example.com", "mail a***@example.com"},
+		{"id 11010119900307123X", "id 110101********123X"},
+		{"id 110101199003071234", "id 110101********1234"},
+		{"a***@example.com 138****5678", "a***@example.com 138****5678"},
+	}
+	for _, tt := range tests {
+		if got := redactText(tt.text); got != tt.want {
+			t.Errorf("redactText(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
+
+const secretHTML = `<h2>Contact</h2><p>call 13812345678 or mail alice@example.com</p>`
+
+func TestRedactOutput(t *testing.T) {
+	tests := []struct {
+		name  string
+		flags map[string]string
+		file  string
+	}{
+		{"plain", map[string]string{}, filepath.Join("a", "Secret.md")},
+		{"html", map[string]string{"format": "both"}, filepath.Join("a", "Secret.html")},
+		{"merge", map[string]string{"merge": "true"}, "a.md"},
+		{"anki", map[string]string{"format": "anki"}, ankiFile},
+	}
+	for _, tt := range tests {
+		for _, copied := range []bool{false, true} {
+			name := tt.name
+			if copied {
+				name += " copy"
+			}
+			t.Run(name, func(t *testing.T) {
+				f := newFakeWiz(t)
+				f.addDoc(&Doc{DocGuid: "doc1", Title: "Secret", Category: "/a/"}, secretHTML)
+				flags := map[string]string{"folders": "/a/", "redact": "true"}
+				for k, v := range tt.flags {
+					flags[k] = v
+				}
+				copyDir := t.TempDir()
+				if copied {
+					flags["redactOutput"] = copyDir
+				}
+				out := setupExport(t, f, flags)
+				if status := exportKb(f.user(), time.Now()); status == nil || status.Counts.Exported != 1 {
+					t.Fatalf("exportKb status = %+v", status)
+				}
+
+				masked := filepath.Join(out, tt.file)
+				if copied {
+					if got := readFile(t, masked); !strings.Contains(got, "13812345678") {
+						t.Errorf("export %s masked: %q", tt.file, got)
+					}
+					masked = filepath.Join(copyDir, tt.file)
+				}
+				got := readFile(t, masked)
+				if strings.Contains(got, "13812345678") || strings.Contains(got, "alice@") {
+					t.Errorf("%s not masked: %q", masked, got)
+				}
+				if !strings.Contains(got, "138****5678") {
+					t.Errorf("%s without the masked phone: %q", masked, got)
+				}
+			})
+		}
+	}
+}
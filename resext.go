@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// the file extensions of the detected content types, the others are kept without one
+var mimeExts = map[string]string{
+	"image/png":       ".png",
+	"image/jpeg":      ".jpg",
+	"image/gif":       ".gif",
+	"image/webp":      ".webp",
+	"image/bmp":       ".bmp",
+	"image/x-icon":    ".ico",
+	"application/pdf": ".pdf",
+	"audio/mpeg":      ".mp3",
+	"audio/wave":      ".wav",
+	"video/mp4":       ".mp4",
+	"video/webm":      ".webm",
+}
+
+// detectExt returns the extension of the content by its mime type, empty if unknown.
+func detectExt(data []byte) string {
+	mime := http.DetectContentType(data)
+	if i := strings.IndexByte(mime, ';'); i >= 0 {
+		mime = mime[:i]
+	}
+	return mimeExts[mime]
+}
+
+// existingExt returns the extension of a file downloaded before for the resource without one.
+func existingExt(resPath string) (string, bool) {
+	for _, ext := range mimeExts {
+		if _, err := os.Stat(resPath + ext); err == nil {
+			return ext, true
+		}
+	}
+	return "", false
+}
+
+// addResExts downloads the resources without an extension into dir before the doc is written,
+// names them by their content type and points the references of the markdown and html to them.
+// The resources failed to download keep their names and download again with the others.
+func addResExts(ctx context.Context, dir string, wizUser *WizUser, doc *Doc, markdown string, html []byte, resNames []string) (string, []byte, []string) {
+	for i, fname := range resNames {
+		if filepath.Ext(fname) != "" {
+			continue
+		}
+		ext, err := fetchResExt(ctx, filepath.Join(dir, fname), wizUser, doc, fname)
+		if err != nil {
+			logErrorf("\tres %s/%s err: %v\n", doc.DocGuid, fname, err)
+			continue
+		}
+		if ext == "" {
+			continue
+		}
+		logDebugf("\tres: %s/%s -> %s\n", doc.DocGuid, fname, fname+ext)
+		resNames[i] = fname + ext
+		markdown = strings.ReplaceAll(markdown, "](index_files/"+fname+")", "](index_files/"+fname+ext+")")
+		for _, quote := range []string{`"`, `'`} {
+			html = []byte(strings.ReplaceAll(string(html), "index_files/"+fname+quote, "index_files/"+fname+ext+quote))
+		}
+	}
+	return markdown, html, resNames
+}
+
+// fetchResExt downloads the resource without an extension and returns the extension it is saved with.
+func fetchResExt(ctx context.Context, resPath string, wizUser *WizUser, doc *Doc, fileName string) (string, error) {
+	if ext, ok := existingExt(resPath); ok {
+		reuseThumb(doc.DocGuid, resPath+ext)
+		return ext, nil
+	}
+	if _, err := os.Stat(resPath); err == nil {
+		reuseThumb(doc.DocGuid, resPath)
+		return "", nil
+	}
+	if err := os.MkdirAll(filepath.Dir(resPath), 0755); err != nil {
+		return "", WrapErr("MkdirAll assets", err)
+	}
+	data, err := taskClient(ctx, wizUser).Resource(doc.DocGuid, fileName, *resAccept)
+	if err != nil {
+		return "", err
+	}
+	ext := detectExt(data)
+	resPath += ext
+	recordAsset(resPath, resourceURL(wizUser, doc, fileName))
+	if err := writeFile(resPath, data); err != nil {
+		return "", WrapErr("WriteFile res", err)
+	}
+	resDownloaded(doc.DocGuid, resPath)
+	makeThumb(doc.DocGuid, resPath, data)
+	return ext, nil
+}
@@ -0,0 +1,27 @@
+package main
+
+import "testing"
+
+func TestDetectExt(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want string
+	}{
+		{"png", "\x89PNG\r\n\x1a\n....", ".png"},
+		{"jpeg", "\xff\xd8\xff\xe0....", ".jpg"},
+		{"gif", "GIF89a....", ".gif"},
+		{"webp", "RIFF\x00\x00\x00\x00WEBPVP8 ", ".webp"},
+		{"pdf", "%PDF-1.7\n", ".pdf"},
+		{"text", "plain text", ""},
+		{"html", "<html><body>x</body></html>", ""},
+		{"empty", "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := detectExt([]byte(tt.data)); got != tt.want {
+				t.Errorf("detectExt(%q) = %q, want %q", tt.data, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/GalaIO/wiz_export/wiz"
+)
+
+var (
+	maxResSize = flag.String("max-res-size", "", "skip resources and attachments larger than the size, like 20MB, keeping their wiz links in the doc")
+
+	maxResBytes int64
+	largeResMu  sync.Mutex
+	largeRes    []string
+)
+
+// resourceURL returns the wiz download url of a doc resource.
+func resourceURL(wizUser *WizUser, doc *Doc, fileName string) string {
+	return kbClient(wizUser).ResourceURL(doc.DocGuid, fileName)
+}
+
+// resContentLength returns the size of the resource from a HEAD request, -1 if unknown. It is
+// retried and its token refreshed like the downloads.
+func resContentLength(ctx context.Context, url, token string) (int64, error) {
+	size := int64(-1)
+	_, err := retryRequest(ctx, url, token, func(token string) ([]byte, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodHead, url, nil)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("X-Wiz-Token", token)
+		if *resAccept != "" {
+			req.Header.Set("Accept", *resAccept)
+		}
+		resp, err := httpClient.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		resp.Body.Close()
+		switch {
+		case resp.StatusCode == http.StatusOK:
+			size = resp.ContentLength
+		case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode >= 500:
+			return nil, &wiz.StatusError{Code: resp.StatusCode, Status: resp.Status}
+		}
+		// the other statuses, like HEAD not allowed, leave the size unknown
+		return nil, nil
+	})
+	return size, err
+}
+
+// skipLargeRes links the resources over --max-res-size to wiz instead of downloading them,
+// their sizes are probed at the same time like the downloads.
+func skipLargeRes(ctx context.Context, wizUser *WizUser, doc *Doc, markdown string) string {
+	if maxResBytes <= 0 {
+		return markdown
+	}
+	names := docResources(markdown)
+	sizes := make([]int64, len(names))
+	var wg sync.WaitGroup
+	resSlots := make(chan struct{}, resWorkerCount())
+	for i, fname := range names {
+		i, fname := i, fname
+		resSlots <- struct{}{}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-resSlots }()
+			size, err := resContentLength(ctx, resourceURL(wizUser, doc, fname), wizUser.CurrentToken())
+			if err != nil {
+				logErrorf("\thead res err: %v %v\n", fname, err)
+			}
+			sizes[i] = size
+		}()
+	}
+	wg.Wait()
+	for i, fname := range names {
+		if sizes[i] <= maxResBytes {
+			continue
+		}
+		resURL := resourceURL(wizUser, doc, fname)
+		logInfof("\tskip large res: %s, size: %v\n", fname, sizes[i])
+		markdown = strings.ReplaceAll(markdown, "](index_files/"+fname+")", "]("+resURL+")")
+		addLargeRes(doc, fname, sizes[i])
+	}
+	return markdown
+}
+
+// skipLargeAttachments keeps the attachments over --max-res-size in wiz, they are linked to
+// their wiz url instead of downloaded.
+func skipLargeAttachments(wizUser *WizUser, doc *Doc, atts []*Attachment) {
+	if maxResBytes <= 0 {
+		return
+	}
+	for _, att := range atts {
+		if att.DataSize <= maxResBytes {
+			continue
+		}
+		logInfof("\tskip large attachment: %s, size: %v\n", att.Name, att.DataSize)
+		att.remote = attachmentURL(wizUser, doc, att)
+		addLargeRes(doc, att.Name, att.DataSize)
+	}
+}
+
+func addLargeRes(doc *Doc, name string, size int64) {
+	largeResMu.Lock()
+	defer largeResMu.Unlock()
+	largeRes = append(largeRes, fmt.Sprintf("%s/%s (%s): %v bytes", doc.Title, name, doc.DocGuid, size))
+}
+
+func printLargeRes() {
+	if len(largeRes) == 0 {
+		return
+	}
+	logInfof("Large resource info:\n\tskipped: %v\n", len(largeRes))
+	for _, res := range largeRes {
+		logInfof("\t%s\n", res)
+	}
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"sync"
+	"time"
+)
+
+var (
+	retryRounds  = flag.Int("retryRounds", 3, "rounds to retry failed folders, docs and resources after the export")
+	retryBackoff = flag.Duration("retryBackoff", time.Second, "backoff before the first retry round, doubled every round")
+	retryBudget  = flag.Duration("retry-budget", 0, "total time of all the attempts of a doc or resource, the first included, like 2m, it fails once exceeded, 0 means no limit")
+
+	retryMu    sync.Mutex
+	retryQueue []*retryTask
+	// tasks given up as over the retry budget or stopped by the disk limit
+	retryExhausted []*retryTask
+)
+
+type retryTask struct {
+	name string
+	// run is canceled once the retry budget of the task is spent
+	run func(ctx context.Context) error
+	err error
+	// the end of the retry budget, counted from the first attempt, zero means no limit
+	deadline time.Time
+	// the resource to download again, nil for the other tasks
+	res *FailedRes
+	// the doc or folder to export again, nil for the other tasks
+	doc *FailedDoc
+}
+
+// enqueueRetry records a failed task, it is run again by drainRetryQueue once the export round is done.
+func enqueueRetry(name string, err error, run func(ctx context.Context) error) {
+	queueRetry(&retryTask{name: name, run: run, err: err})
+}
+
+// runTask runs the first attempt of the task, which starts its retry budget, and queues the
+// task for retry if it fails.
+func runTask(task *retryTask) {
+	startBudget(task)
+	if task.err = attemptTask(task); task.err != nil {
+		queueRetry(task)
+	}
+}
+
+func startBudget(task *retryTask) {
+	if *retryBudget > 0 && task.deadline.IsZero() {
+		task.deadline = time.Now().Add(*retryBudget)
+	}
+}
+
+// attemptTask runs the task once, canceled at the deadline of its budget.
+func attemptTask(task *retryTask) error {
+	ctx, cancel := context.WithCancel(exportCtx)
+	if !task.deadline.IsZero() {
+		ctx, cancel = context.WithDeadline(exportCtx, task.deadline)
+	}
+	defer cancel()
+	return task.run(ctx)
+}
+
+// overBudget reports whether the retry budget of the task is spent.
+func overBudget(task *retryTask) bool {
+	return !task.deadline.IsZero() && !time.Now().Before(task.deadline)
+}
+
+func queueRetry(task *retryTask) {
+	// the tasks failed before runTask start their budget here
+	startBudget(task)
+	taskErrorEvent(task)
+	retryMu.Lock()
+	defer retryMu.Unlock()
+	if errors.Is(task.err, errDiskLimit) {
+		// no retry until there is space, the task is listed as failed for --retryFailed and --retryRes
+		logErrorf("\tstop: %s, err: %v\n", task.name, task.err)
+		retryExhausted = append(retryExhausted, task)
+		return
+	}
+	logInfof("\tqueue retry: %s, err: %v\n", task.name, task.err)
+	retryQueue = append(retryQueue, task)
+}
+
+// drainRetryQueue retries the queued tasks round by round with exponential backoff,
+// tasks enqueued while retrying join the next round. It returns the tasks failed in all rounds.
+func drainRetryQueue() []*retryTask {
+	backoff := *retryBackoff
+	for round := 1; round <= *retryRounds && !exportStopped(); round++ {
+		retryMu.Lock()
+		tasks := retryQueue
+		retryQueue = nil
+		retryMu.Unlock()
+		if len(tasks) == 0 {
+			break
+		}
+
+		logInfof("Retry round %d/%d:\n\tcount: %v\n\tbackoff: %s\n", round, *retryRounds, len(tasks), backoff)
+		select {
+		case <-time.After(backoff):
+		case <-exportCtx.Done():
+		}
+		for _, task := range tasks {
+			if overBudget(task) {
+				logErrorf("\tgive up %s, over the retry budget %s\n", task.name, *retryBudget)
+				retryMu.Lock()
+				retryExhausted = append(retryExhausted, task)
+				retryMu.Unlock()
+				continue
+			}
+			if err := attemptTask(task); err != nil {
+				logErrorf("\tretry %s err: %v\n", task.name, err)
+				task.err = err
+				retryMu.Lock()
+				retryQueue = append(retryQueue, task)
+				retryMu.Unlock()
+			}
+		}
+		backoff *= 2
+	}
+
+	retryMu.Lock()
+	defer retryMu.Unlock()
+	failed := append(retryExhausted, retryQueue...)
+	retryQueue, retryExhausted = nil, nil
+	return failed
+}
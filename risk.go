@@ -0,0 +1,83 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	riskBackoff = flag.Duration("riskBackoff", 5*time.Minute, "pause of all requests once wiz rate limits the account, doubled on every hit, "+
+		"a 429 waits its Retry-After or backs off the request by --retryDelay instead")
+	riskRetries = flag.Int("riskRetries", 3, "retries of a request rate limited by wiz")
+
+	// wiz return codes and messages of the rate limit / risk control
+	riskCodes    = map[int]bool{http.StatusTooManyRequests: true, 31002: true, 31003: true}
+	riskKeywords = []string{"频繁", "风控", "限制访问", "too many", "too frequent", "rate limit"}
+)
+
+type riskError struct {
+	code int
+	msg  string
+	// retryAfter is the wait asked by the Retry-After header, 0 if none
+	retryAfter time.Duration
+}
+
+func (e *riskError) Error() string {
+	return fmt.Sprintf("wiz rate limited, code: %v, msg: %s", e.code, e.msg)
+}
+
+// checkRisk inspects the response for the rate limit / risk control of wiz, which
+// comes either as the http status or as a json returnCode with a plain 200.
+func checkRisk(status int, body []byte) *riskError {
+	rc := new(ResultCode)
+	if b := bytes.TrimSpace(body); len(b) > 0 && b[0] == '{' {
+		_ = json.Unmarshal(b, rc)
+	}
+	if riskCodes[status] {
+		return &riskError{code: status, msg: rc.ReturnMessage}
+	}
+	if rc.ReturnCode == 0 || rc.ReturnCode == 200 {
+		return nil
+	}
+	if riskCodes[rc.ReturnCode] {
+		return &riskError{code: rc.ReturnCode, msg: rc.ReturnMessage}
+	}
+	msg := strings.ToLower(rc.ReturnMessage)
+	for _, keyword := range riskKeywords {
+		if strings.Contains(msg, keyword) {
+			return &riskError{code: rc.ReturnCode, msg: rc.ReturnMessage}
+		}
+	}
+	return nil
+}
+
+// retryAfter parses the Retry-After header, in seconds or an http date.
+func retryAfter(header string) time.Duration {
+	if header == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(strings.TrimSpace(header)); err == nil && secs > 0 {
+		return time.Duration(secs) * time.Second
+	}
+	if t, err := http.ParseTime(header); err == nil {
+		return time.Until(t)
+	}
+	return 0
+}
+
+// waitRisk pauses all requests for the Retry-After of the response, or the backoff of the attempt.
+func waitRisk(err *riskError, attempt int) {
+	backoff := *riskBackoff << attempt
+	if err.retryAfter > 0 {
+		backoff = err.retryAfter
+	}
+	logInfof("Rate limited:\n\t%v\n\thint: lower --concurrency, raise --interval or retry later\n\tpause: %s\n",
+		err, backoff)
+	requestPacer.PauseUntil(time.Now().Add(backoff))
+}
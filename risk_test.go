@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/GalaIO/wiz_export/wiz"
+)
+
+func TestRetryRequestRateLimit(t *testing.T) {
+	tests := []struct {
+		name     string
+		statuses []int
+		// retryAfter is the Retry-After header of the 429s
+		retryAfter string
+		wantErr    bool
+		paused     bool
+	}{
+		{"429 backs off the request", []int{429, 429}, "", false, false},
+		{"429 with Retry-After pauses all", []int{429}, "1", false, true},
+		{"rate limits counted apart from the transient errors", []int{500, 500, 429, 429, 429}, "", false, false},
+		{"rate limits over riskRetries", []int{429, 429, 429, 429}, "", true, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var n int32
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				i := int(atomic.AddInt32(&n, 1)) - 1
+				if i >= len(tt.statuses) {
+					w.Write([]byte("ok"))
+					return
+				}
+				if tt.statuses[i] == http.StatusTooManyRequests && tt.retryAfter != "" {
+					w.Header().Set("Retry-After", tt.retryAfter)
+				}
+				w.WriteHeader(tt.statuses[i])
+			}))
+			defer server.Close()
+			setFlags(t, map[string]string{"retryDelay": "1ms", "maxRetries": "2", "riskRetries": "3", "riskBackoff": "1h", "interval": "0"})
+			prevClient := wizClient
+			wizClient = wiz.NewClient(server.URL)
+			wizClient.HTTPClient = server.Client()
+			requestPacer = new(pacer)
+			t.Cleanup(func() {
+				wizClient = prevClient
+				requestPacer = new(pacer)
+			})
+
+			rs, err := fetchAccept(context.Background(), server.URL, "", "")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("fetchAccept = %q, %v, wantErr %v", rs, err, tt.wantErr)
+			}
+			requestPacer.mu.Lock()
+			paused := !requestPacer.paused.IsZero()
+			requestPacer.mu.Unlock()
+			if paused != tt.paused {
+				t.Errorf("requests paused %v, want %v", paused, tt.paused)
+			}
+		})
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	if got := retryAfter("3"); got != 3*time.Second {
+		t.Errorf("retryAfter(3) = %s", got)
+	}
+	if got := retryAfter(""); got != 0 {
+		t.Errorf("retryAfter() = %s", got)
+	}
+	if got := retryAfter(time.Now().Add(time.Minute).UTC().Format(http.TimeFormat)); got <= 0 || got > time.Minute {
+		t.Errorf("retryAfter(date) = %s", got)
+	}
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+var splitByHeading = flag.Int("split-by-heading", 0, "split docs into a file per heading up to the level, like 2, into a folder named by the title")
+
+type docPart struct {
+	name    string
+	content string
+}
+
+// splitDoc splits the markdown at the headings up to the level, parts are named by their
+// position and heading, the text before the first heading becomes the 00 part.
+func splitDoc(title, markdown string, level int) []docPart {
+	var parts []docPart
+	for i, sec := range splitSections(markdown, level) {
+		name, body := sec.title, sec.body
+		if sec.level == 0 {
+			if strings.TrimSpace(body) == "" {
+				continue
+			}
+			name = strings.TrimSuffix(title, ".md")
+		} else {
+			body = strings.Repeat("#", sec.level) + " " + sec.title + "\n" + body
+		}
+		parts = append(parts, docPart{name: fmt.Sprintf("%02d-%s.md", i, sanitizeFileName(name)), content: body})
+	}
+	return parts
+}
+
+// writeParts writes the parts into dir, each with the same front matter head.
+func writeParts(dir, head string, parts []docPart) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return WrapErr("MkdirAll parts", err)
+	}
+	for _, part := range parts {
+		if err := writeDoc(filepath.Join(dir, part.name), []byte(head+part.content)); err != nil {
+			return WrapErr("WriteFile part", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const stateFile = ".wiz_export_state.json"
+
+type DocState struct {
+	// Exported is the unix time in milliseconds
+	Exported int64 `json:"exported"`
+	// Modified is the wiz modified time of the doc when exported
+	Modified int `json:"modified"`
+}
+
+var (
+	stateMu   sync.Mutex
+	docStates = make(map[string]*DocState)
+)
+
+// docModified returns the modified time of the doc, its created time if unknown.
+func docModified(doc *Doc) int {
+	if doc.Modified > 0 {
+		return doc.Modified
+	}
+	return doc.Created
+}
+
+func loadState(root string) error {
+	bs, err := ioutil.ReadFile(filepath.Join(root, stateFile))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return WrapErr("read state", err)
+	}
+	stateMu.Lock()
+	defer stateMu.Unlock()
+	return WrapErr("Unmarshal state", json.Unmarshal(bs, &docStates))
+}
+
+func saveState(root string) error {
+	stateMu.Lock()
+	bs, err := json.MarshalIndent(docStates, "", "  ")
+	stateMu.Unlock()
+	if err != nil {
+		return WrapErr("Marshal state", err)
+	}
+	return WrapErr("WriteFile state", atomicWriteFile(filepath.Join(root, stateFile), bs, 0644))
+}
+
+func recordState(doc *Doc) {
+	stateMu.Lock()
+	defer stateMu.Unlock()
+	docStates[doc.DocGuid] = &DocState{Exported: time.Now().UnixNano() / int64(time.Millisecond), Modified: docModified(doc)}
+}
+
+// remoteUnchanged reports whether the doc was not modified in wiz since its last export,
+// and its files of the last export are still there.
+func remoteUnchanged(doc *Doc) bool {
+	stateMu.Lock()
+	st, ok := docStates[doc.DocGuid]
+	stateMu.Unlock()
+	modified := docModified(doc)
+	if !ok || modified <= 0 {
+		return false
+	}
+	if st.Modified != modified && docTime(modified).UnixNano()/int64(time.Millisecond) > st.Exported {
+		return false
+	}
+	manifestMu.Lock()
+	prev, ok := prevDocs[doc.DocGuid]
+	manifestMu.Unlock()
+	if !ok {
+		return false
+	}
+	files := prev.Parts
+	if len(files) == 0 {
+		files = []string{prev.Path}
+	}
+	for _, file := range files {
+		if _, err := os.Stat(localPath(exportRoot, file)); err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// skipUnchanged skips the export of the docs unchanged since the last incremental export,
+// their manifest entries are kept.
+func skipUnchanged(doc *Doc) bool {
+	if !*incremental || !remoteUnchanged(doc) {
+		return false
+	}
+	manifestMu.Lock()
+	prev := prevDocs[doc.DocGuid]
+	manifestMu.Unlock()
+	if len(prev.Parts) > 0 {
+		// the folder of a split doc is named after its file
+		reserveDocFile(prev.Path+".md", doc.DocGuid)
+	} else {
+		reserveDocFile(prev.Path, doc.DocGuid)
+	}
+	logInfof("\tskip unchanged: %s %s\n", doc.DocGuid, doc.Title)
+	atomic.AddInt64(&docsSkipped, 1)
+	return true
+}
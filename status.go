@@ -0,0 +1,182 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const statusFile = "status.json"
+
+var (
+	statusMu sync.Mutex
+	// errors of the steps after the export
+	stepErrors []string
+
+	// docs to export of the folders listed so far, and docs taken by the workers
+	docsQueued int64
+	docsDone   int64
+	resFetched int64
+)
+
+// progress returns the doc count like [120/600], the total grows as folders are listed.
+func progress() string {
+	done := atomic.AddInt64(&docsDone, 1)
+	return fmt.Sprintf("[%d/%d]", done, atomic.LoadInt64(&docsQueued))
+}
+
+type ExportStatus struct {
+	Success  bool          `json:"success"`
+	Started  string        `json:"started"`
+	Finished string        `json:"finished"`
+	Counts   *StatusCounts `json:"counts"`
+	Errors   []string      `json:"errors"`
+	// Kbs are the status of every kb in the status.json of the output, exported into kb folders
+	Kbs map[string]*ExportStatus `json:"kbs,omitempty"`
+}
+
+type StatusCounts struct {
+	Listed   int   `json:"listed"`
+	Exported int64 `json:"exported"`
+	Skipped  int64 `json:"skipped"`
+	Failed   int   `json:"failed"`
+	LargeRes int   `json:"largeRes"`
+	// Resources counts the resources and attachments downloaded
+	Resources int64 `json:"resources"`
+}
+
+// stepErr prints and records the error of a step after the export.
+func stepErr(step string, err error) {
+	logErrorf("%s err: %v\n", step, err)
+	statusMu.Lock()
+	defer statusMu.Unlock()
+	stepErrors = append(stepErrors, fmt.Sprintf("%s: %v", step, err))
+}
+
+// printSummary prints the counts of the export, its time and the failed docs with their errors.
+func printSummary(started time.Time, failed []*retryTask) {
+	manifestMu.Lock()
+	folders, listed := len(listedFolders), len(listedDocs)
+	manifestMu.Unlock()
+	var failedDocs []*retryTask
+	res := 0
+	for _, task := range failed {
+		switch {
+		case task.res != nil:
+			res++
+		case strings.HasPrefix(task.name, "doc "):
+			failedDocs = append(failedDocs, task)
+		}
+	}
+	logEvent(&LogEvent{Type: "summary", Elapsed: time.Since(started).Round(time.Second).String(), Counts: &StatusCounts{
+		Listed:    listed,
+		Exported:  atomic.LoadInt64(&docsExported),
+		Skipped:   atomic.LoadInt64(&docsSkipped),
+		Failed:    len(failed),
+		LargeRes:  len(largeRes),
+		Resources: atomic.LoadInt64(&resFetched),
+	}})
+	logResultf("Summary:\n\tfolders: %v\n\tdocs: %v\n\texported: %v\n\tskipped: %v\n\tfailed docs: %v\n"+
+		"\tresources: %v\n\tfailed resources: %v\n\tother failures: %v\n\telapsed: %s\n",
+		folders, listed, atomic.LoadInt64(&docsExported), atomic.LoadInt64(&docsSkipped), len(failedDocs),
+		atomic.LoadInt64(&resFetched), res, len(failed)-len(failedDocs)-res, time.Since(started).Round(time.Second))
+	for _, task := range failedDocs {
+		logResultf("\t%s: %v\n", strings.TrimPrefix(task.name, "doc "), task.err)
+	}
+	printEmptyFolders()
+}
+
+// resetCounts clears the counters of the kb exported before, every kb counts its own docs.
+func resetCounts() {
+	for _, n := range []*int64{&docsExported, &docsSkipped, &docsQueued, &docsDone, &resFetched, &readingMinutes} {
+		atomic.StoreInt64(n, 0)
+	}
+	statusMu.Lock()
+	stepErrors = nil
+	statusMu.Unlock()
+}
+
+// writeStatus writes status.json of the kb for pipelines to check the result of the export, and
+// returns it.
+func writeStatus(root string, started time.Time, failed []*retryTask) (*ExportStatus, error) {
+	manifestMu.Lock()
+	listed := len(listedDocs)
+	manifestMu.Unlock()
+	statusMu.Lock()
+	errs := []string{}
+	for _, task := range failed {
+		errs = append(errs, fmt.Sprintf("%s: %v", task.name, task.err))
+	}
+	errs = append(errs, stepErrors...)
+	statusMu.Unlock()
+	if atomic.LoadInt32(&diskFull) == 1 {
+		errs = append(errs, errDiskLimit.Error())
+	}
+	if interrupted() {
+		errs = append(errs, errInterrupted.Error())
+	}
+
+	status := &ExportStatus{
+		Success:  len(errs) == 0,
+		Started:  started.Format(time.RFC3339),
+		Finished: time.Now().Format(time.RFC3339),
+		Counts: &StatusCounts{
+			Listed:    listed,
+			Exported:  atomic.LoadInt64(&docsExported),
+			Skipped:   atomic.LoadInt64(&docsSkipped),
+			Failed:    len(failed),
+			LargeRes:  len(largeRes),
+			Resources: atomic.LoadInt64(&resFetched),
+		},
+		Errors: errs,
+	}
+	return status, saveStatus(root, status)
+}
+
+// writeKbsStatus writes status.json of the output with the kbs exported into their folders,
+// their counts summed.
+func writeKbsStatus(output string, started time.Time, kbs map[string]*ExportStatus) error {
+	status := &ExportStatus{
+		Success:  true,
+		Started:  started.Format(time.RFC3339),
+		Finished: time.Now().Format(time.RFC3339),
+		Counts:   new(StatusCounts),
+		Errors:   []string{},
+		Kbs:      kbs,
+	}
+	names := make([]string, 0, len(kbs))
+	for name := range kbs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		kb := kbs[name]
+		status.Success = status.Success && kb.Success
+		status.Counts.Listed += kb.Counts.Listed
+		status.Counts.Exported += kb.Counts.Exported
+		status.Counts.Skipped += kb.Counts.Skipped
+		status.Counts.Failed += kb.Counts.Failed
+		status.Counts.LargeRes += kb.Counts.LargeRes
+		status.Counts.Resources += kb.Counts.Resources
+		for _, e := range kb.Errors {
+			status.Errors = append(status.Errors, name+": "+e)
+		}
+	}
+	return saveStatus(output, status)
+}
+
+func saveStatus(root string, status *ExportStatus) error {
+	bs, err := json.MarshalIndent(status, "", "  ")
+	if err != nil {
+		return WrapErr("Marshal status", err)
+	}
+	if err := atomicWriteFile(filepath.Join(root, statusFile), bs, 0644); err != nil {
+		return WrapErr("WriteFile status", err)
+	}
+	return nil
+}
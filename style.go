@@ -0,0 +1,59 @@
+package main
+
+import (
+	"flag"
+	"strings"
+
+	md "github.com/JohannesKaufmann/html-to-markdown"
+	"github.com/PuerkitoBio/goquery"
+)
+
+var keepStyles = flag.String("keepStyles", "", "inline styles kept as html spans, like color,background-color, the others are dropped")
+
+// inlineStyles keeps the whitelisted inline styles of spans and fonts, like colored marks,
+// as <span style> around the converted content.
+func inlineStyles() md.Plugin {
+	return func(c *md.Converter) []md.Rule {
+		keep := make(map[string]bool)
+		for _, name := range strings.Split(*keepStyles, ",") {
+			if name = strings.ToLower(strings.TrimSpace(name)); name != "" {
+				keep[name] = true
+			}
+		}
+		if len(keep) == 0 {
+			return nil
+		}
+		return []md.Rule{{
+			Filter: []string{"span", "font", "mark"},
+			Replacement: func(content string, selec *goquery.Selection, opt *md.Options) *string {
+				style := keptStyle(selec, keep)
+				if style == "" || strings.TrimSpace(content) == "" {
+					// like the default rule, no other rule handles them
+					return &content
+				}
+				text := `<span style="` + style + `">` + content + `</span>`
+				return &text
+			},
+		}}
+	}
+}
+
+// keptStyle returns the whitelisted declarations of the style attribute, and the color of a font.
+func keptStyle(selec *goquery.Selection, keep map[string]bool) string {
+	var kept []string
+	if color, ok := selec.Attr("color"); ok && keep["color"] {
+		kept = append(kept, "color: "+color)
+	}
+	for _, decl := range strings.Split(selec.AttrOr("style", ""), ";") {
+		kv := strings.SplitN(decl, ":", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		name, value := strings.ToLower(strings.TrimSpace(kv[0])), strings.TrimSpace(kv[1])
+		// background covers background-color
+		if value != "" && (keep[name] || name == "background-color" && keep["background"]) {
+			kept = append(kept, name+": "+strings.ReplaceAll(value, `"`, "'"))
+		}
+	}
+	return strings.Join(kept, "; ")
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+const (
+	tagIndexFile = "tags.md"
+	tagsFile     = "tags.json"
+)
+
+var (
+	tagIndex   = flag.Bool("tagIndex", false, "write tags.md listing the docs of every keyword")
+	folderTags = flag.Bool("tagsFile", false, "write tags.json in every folder with the tags of its docs")
+	slashTags  = flag.Bool("slashTags", false, "keep the slashes in the keywords as part of the tags, like C/C++, instead of separating the tags by them")
+)
+
+// DocTags are the tags of a doc in the tags.json of its folder.
+type DocTags struct {
+	DocGuid string `json:"docGuid"`
+	Title   string `json:"title"`
+	// File is relative to the folder
+	File string   `json:"file"`
+	Tags []string `json:"tags"`
+}
+
+// parseKeywords normalizes the keywords of a doc into tags: separated by commas, semicolons,
+// slashes unless --slashTags or spaces, without the leading # and duplicates, which differ in
+// case only too.
+func parseKeywords(keywords string) []string {
+	var tags []string
+	seen := make(map[string]bool)
+	for _, tag := range strings.FieldsFunc(keywords, isKeywordSep) {
+		tag = strings.TrimLeft(tag, "#＃@")
+		if tag == "" || seen[strings.ToLower(tag)] {
+			continue
+		}
+		seen[strings.ToLower(tag)] = true
+		tags = append(tags, tag)
+	}
+	return tags
+}
+
+func isKeywordSep(r rune) bool {
+	switch r {
+	case ',', '，', ';', '；', '、', '|':
+		return true
+	case '/':
+		return !*slashTags
+	}
+	return unicode.IsSpace(r)
+}
+
+// writeTagIndex writes tags.md with a section of doc links per tag, from the manifest. Tags
+// differing in case only share a section, named by the first spelling.
+func writeTagIndex(root string) error {
+	tagDocs := make(map[string][]*ManifestEntry)
+	names := make(map[string]string)
+	for _, entry := range buildManifest().Docs {
+		for _, tag := range entry.Keywords {
+			key := strings.ToLower(tag)
+			if _, ok := names[key]; !ok {
+				names[key] = tag
+			}
+			tagDocs[key] = append(tagDocs[key], entry)
+		}
+	}
+	keys := make([]string, 0, len(tagDocs))
+	for key := range tagDocs {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var buf bytes.Buffer
+	buf.WriteString("# Tags\n")
+	for _, key := range keys {
+		fmt.Fprintf(&buf, "\n## %s (%d)\n\n", names[key], len(tagDocs[key]))
+		for _, entry := range tagDocs[key] {
+			fmt.Fprintf(&buf, "- [%s](<%s>)\n", entry.Title, entryLink(entry))
+		}
+	}
+	if err := atomicWriteFile(filepath.Join(root, tagIndexFile), buf.Bytes(), 0644); err != nil {
+		return WrapErr("WriteFile tags", err)
+	}
+	return nil
+}
+
+// entryLink returns the file to link for the doc, the first part of a split doc.
+func entryLink(entry *ManifestEntry) string {
+	if len(entry.Parts) > 0 {
+		return entry.Parts[0]
+	}
+	return entry.Path
+}
+
+// writeFolderTags writes tags.json next to the docs of every folder, for the docs with tags.
+func writeFolderTags(root string) error {
+	folders := make(map[string][]*DocTags)
+	for _, entry := range buildManifest().Docs {
+		if len(entry.Keywords) == 0 {
+			continue
+		}
+		dir := path.Dir(entry.Path)
+		folders[dir] = append(folders[dir], &DocTags{
+			DocGuid: entry.DocGuid,
+			Title:   entry.Title,
+			File:    path.Base(entry.Path),
+			Tags:    entry.Keywords,
+		})
+	}
+	for dir, docs := range folders {
+		bs, err := json.MarshalIndent(docs, "", "  ")
+		if err != nil {
+			return WrapErr("Marshal tags", err)
+		}
+		if err := atomicWriteFile(filepath.Join(localPath(root, dir), tagsFile), bs, 0644); err != nil {
+			return WrapErr("WriteFile tags.json", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+func TestParseKeywords(t *testing.T) {
+	tests := []struct {
+		keywords  string
+		slashTags bool
+		want      []string
+	}{
+		{"", false, nil},
+		{"go,rust", false, []string{"go", "rust"}},
+		{"go/rust", false, []string{"go", "rust"}},
+		{"go，rust；笔记、 #todo", false, []string{"go", "rust", "笔记", "todo"}},
+		{"Go,go,GO", false, []string{"Go"}},
+		{"#,＃tag,@tag", false, []string{"tag"}},
+		{"C/C++,c", false, []string{"C", "C++"}},
+		{"C/C++,c/c++", true, []string{"C/C++"}},
+		{"C/C++ go", true, []string{"C/C++", "go"}},
+	}
+	for _, tt := range tests {
+		setFlags(t, map[string]string{"slashTags": strconv.FormatBool(tt.slashTags)})
+		if got := parseKeywords(tt.keywords); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseKeywords(%q) with slashTags %v = %q, want %q", tt.keywords, tt.slashTags, got, tt.want)
+		}
+	}
+}
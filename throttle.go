@@ -0,0 +1,220 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	concurrency    = flag.Int("concurrency", 4, "workers exporting docs at the same time, the control file can raise it up to 32 or lower it at runtime")
+	resConcurrency = flag.Int("resConcurrency", 4, "resources of a doc downloaded at the same time")
+	interval       = flag.Duration("interval", 100*time.Millisecond, "min interval between requests, the refill of the rate limiter")
+	burst          = flag.Int("burst", 1, "requests sent at once before --interval applies, the bucket size of the rate limiter")
+	control        = flag.String("control", "", "control file polled at runtime to raise or lower the concurrency, up to 32, adjust the interval or pause, "+
+		`like {"concurrency": 2, "interval": "200ms", "paused": false}`)
+
+	maxTasks = flag.Int("maxTasks", 0, "max doc and resource tasks running in total, 0 means no limit")
+
+	docGate      = newGate(4)
+	requestPacer = new(pacer)
+	// taskGate limits the doc and resource tasks together, nil without --maxTasks
+	taskGate *gate
+)
+
+// gate is a semaphore whose limit can be changed while it is in use.
+type gate struct {
+	mu     sync.Mutex
+	cond   *sync.Cond
+	limit  int
+	active int
+}
+
+func newGate(limit int) *gate {
+	g := &gate{limit: limit}
+	g.cond = sync.NewCond(&g.mu)
+	return g
+}
+
+func (g *gate) Acquire() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	for g.active >= g.limit {
+		g.cond.Wait()
+	}
+	g.active++
+}
+
+func (g *gate) Release() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.active--
+	g.cond.Broadcast()
+}
+
+func (g *gate) SetLimit(limit int) {
+	if limit < 1 {
+		limit = 1
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.limit = limit
+	g.cond.Broadcast()
+}
+
+// maxControlConcurrency is the most workers the control file can raise the concurrency to.
+const maxControlConcurrency = 32
+
+// workerCount returns the workers to start for a folder. With --control as many are started as
+// the control file may ask for, docGate lets through the current concurrency.
+func workerCount() int {
+	n := *concurrency
+	if *control != "" && n < maxControlConcurrency {
+		n = maxControlConcurrency
+	}
+	if n < 1 {
+		return 1
+	}
+	return n
+}
+
+func resWorkerCount() int {
+	if *resConcurrency < 1 {
+		return 1
+	}
+	return *resConcurrency
+}
+
+// acquireTask takes a slot of taskGate, the returned release can be called more than once.
+func acquireTask() func() {
+	if taskGate == nil {
+		return func() {}
+	}
+	taskGate.Acquire()
+	var once sync.Once
+	return func() { once.Do(taskGate.Release) }
+}
+
+// pacer is a token bucket limiting the requests: a bucket of burst tokens refilled one per interval,
+// both can be changed while it is in use.
+type pacer struct {
+	mu       sync.Mutex
+	interval time.Duration
+	burst    int
+	// next is when the bucket is full again, every request takes an interval from it
+	next   time.Time
+	paused time.Time
+}
+
+// Wait takes a token, waiting for it until ctx is done.
+func (p *pacer) Wait(ctx context.Context) error {
+	p.mu.Lock()
+	now := time.Now()
+	if p.next.Before(now) {
+		p.next = now
+	}
+	burst := p.burst
+	if burst < 1 {
+		burst = 1
+	}
+	wait := p.next.Add(-time.Duration(burst-1) * p.interval).Sub(now)
+	if paused := p.paused.Sub(now); paused > wait {
+		wait = paused
+	}
+	p.next = p.next.Add(p.interval)
+	p.mu.Unlock()
+	if wait <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// PauseUntil holds back all requests until t.
+func (p *pacer) PauseUntil(t time.Time) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.paused.Before(t) {
+		p.paused = t
+	}
+}
+
+func (p *pacer) SetInterval(d time.Duration) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.interval = d
+}
+
+func (p *pacer) SetBurst(n int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.burst = n
+}
+
+type controlConfig struct {
+	Concurrency int    `json:"concurrency"`
+	Interval    string `json:"interval"`
+	Paused      *bool  `json:"paused"`
+}
+
+// watchControl polls the control file and applies its settings whenever it changes.
+func watchControl(file string) {
+	var modTime time.Time
+	for {
+		if info, err := os.Stat(file); err == nil && !info.ModTime().Equal(modTime) {
+			modTime = info.ModTime()
+			if err := applyControl(file); err != nil {
+				logErrorf("applyControl err: %v\n", err)
+			}
+		}
+		time.Sleep(2 * time.Second)
+	}
+}
+
+func applyControl(file string) error {
+	bs, err := ioutil.ReadFile(file)
+	if err != nil {
+		return WrapErr("read control", err)
+	}
+	cfg := new(controlConfig)
+	if err := json.Unmarshal(bs, cfg); err != nil {
+		return WrapErr("Unmarshal control", err)
+	}
+	if cfg.Concurrency > workerCount() {
+		logErrorf("\tcontrol concurrency %v over the %v workers, use %v\n", cfg.Concurrency, workerCount(), workerCount())
+		cfg.Concurrency = workerCount()
+	}
+	// only the fields set in the file are logged, setPaused logs the pause itself
+	var changed []string
+	if cfg.Concurrency > 0 {
+		docGate.SetLimit(cfg.Concurrency)
+		changed = append(changed, fmt.Sprintf("concurrency: %v", cfg.Concurrency))
+	}
+	if cfg.Interval != "" {
+		d, err := time.ParseDuration(cfg.Interval)
+		if err != nil {
+			return WrapErr("parse interval", err)
+		}
+		requestPacer.SetInterval(d)
+		changed = append(changed, "interval: "+d.String())
+	}
+	if cfg.Paused != nil {
+		setPaused(*cfg.Paused)
+	}
+	if len(changed) > 0 {
+		logInfof("Control:\n\t%s\n", strings.Join(changed, "\n\t"))
+	}
+	return nil
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"image"
+	"image/color"
+	_ "image/gif"
+	"image/jpeg"
+	"image/png"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const thumbsDir = "thumbs"
+
+var thumbSize = flag.Int("thumbs", 0, "write a thumbnail of every downloaded image into <output>/thumbs with this max edge, like 256, 0 means off")
+
+// writeThumb writes the thumbnail of an image resource, returns its path or empty for non images.
+func writeThumb(resPath string, data []byte) (string, error) {
+	src, format, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		// not an image
+		return "", nil
+	}
+	thumbPath := filepath.Join(exportRoot, thumbsDir, relPath(resPath))
+	if err := os.MkdirAll(filepath.Dir(thumbPath), 0755); err != nil {
+		return "", WrapErr("MkdirAll thumbs", err)
+	}
+	var buf bytes.Buffer
+	thumb := scaleDown(src, *thumbSize)
+	if format == "jpeg" {
+		err = jpeg.Encode(&buf, thumb, &jpeg.Options{Quality: 85})
+	} else {
+		thumbPath = strings.TrimSuffix(thumbPath, filepath.Ext(thumbPath)) + ".png"
+		err = png.Encode(&buf, thumb)
+	}
+	if err != nil {
+		return "", WrapErr("encode thumb", err)
+	}
+	if err := writeFile(thumbPath, buf.Bytes()); err != nil {
+		return "", WrapErr("WriteFile thumb", err)
+	}
+	return thumbPath, nil
+}
+
+// scaleDown fits the image into a square of the max edge, averaging the source pixels.
+func scaleDown(src image.Image, max int) image.Image {
+	b := src.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if w <= max && h <= max {
+		return src
+	}
+	tw, th := max, h*max/w
+	if h > w {
+		tw, th = w*max/h, max
+	}
+	if tw < 1 {
+		tw = 1
+	}
+	if th < 1 {
+		th = 1
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, tw, th))
+	for y := 0; y < th; y++ {
+		y0, y1 := b.Min.Y+y*h/th, b.Min.Y+(y+1)*h/th
+		for x := 0; x < tw; x++ {
+			x0, x1 := b.Min.X+x*w/tw, b.Min.X+(x+1)*w/tw
+			var r, g, bl, a, n uint32
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					cr, cg, cb, ca := src.At(sx, sy).RGBA()
+					r, g, bl, a, n = r+cr, g+cg, bl+cb, a+ca, n+1
+				}
+			}
+			dst.Set(x, y, color.RGBA64{uint16(r / n), uint16(g / n), uint16(bl / n), uint16(a / n)})
+		}
+	}
+	return dst
+}
+
+// thumbnails made before the doc is recorded, like of the resources without an extension,
+// docGuid -> resource -> thumbnail, guarded by manifestMu
+var pendingThumbs = make(map[string]map[string]string)
+
+// recordThumb maps the resource of the doc to its thumbnail in the manifest, kept until the doc
+// is recorded if it is not yet.
+func recordThumb(docGuid, resPath, thumbPath string) {
+	manifestMu.Lock()
+	defer manifestMu.Unlock()
+	thumbs := pendingThumbs[docGuid]
+	if entry, ok := exportedDocs[docGuid]; ok {
+		if entry.Thumbs == nil {
+			entry.Thumbs = make(map[string]string)
+		}
+		thumbs = entry.Thumbs
+	} else if thumbs == nil {
+		thumbs = make(map[string]string)
+		pendingThumbs[docGuid] = thumbs
+	}
+	thumbs[relPath(resPath)] = relPath(thumbPath)
+}
+
+// carryThumbs adds the pending thumbnails of the entry, and those of the last export for its
+// resources whose thumbnail is still there. The caller holds manifestMu.
+func carryThumbs(entry *ManifestEntry) {
+	if *thumbSize <= 0 {
+		return
+	}
+	thumbs := pendingThumbs[entry.DocGuid]
+	delete(pendingThumbs, entry.DocGuid)
+	if thumbs == nil {
+		thumbs = make(map[string]string)
+	}
+	if prev, ok := prevDocs[entry.DocGuid]; ok {
+		for _, res := range entry.Resources {
+			thumb, ok := prev.Thumbs[res]
+			if _, set := thumbs[res]; !ok || set {
+				continue
+			}
+			if _, err := os.Stat(localPath(exportRoot, thumb)); err == nil {
+				thumbs[res] = thumb
+			}
+		}
+	}
+	for res, thumb := range entry.Thumbs {
+		thumbs[res] = thumb
+	}
+	if len(thumbs) > 0 {
+		entry.Thumbs = thumbs
+	}
+}
+
+// reuseThumb records the thumbnail of a resource downloaded before, made again if missing.
+func reuseThumb(docGuid, resPath string) {
+	if *thumbSize <= 0 {
+		return
+	}
+	thumbPath := filepath.Join(exportRoot, thumbsDir, relPath(resPath))
+	for _, file := range []string{thumbPath, strings.TrimSuffix(thumbPath, filepath.Ext(thumbPath)) + ".png"} {
+		if _, err := os.Stat(file); err == nil {
+			recordThumb(docGuid, resPath, file)
+			return
+		}
+	}
+	data, err := ioutil.ReadFile(resPath)
+	if err != nil {
+		logErrorf("\tthumb err: %v %v\n", resPath, err)
+		return
+	}
+	makeThumb(docGuid, resPath, data)
+}
+
+func makeThumb(docGuid, resPath string, data []byte) {
+	if *thumbSize <= 0 {
+		return
+	}
+	thumbPath, err := writeThumb(resPath, data)
+	if err != nil {
+		logErrorf("\tthumb err: %v %v\n", resPath, err)
+		return
+	}
+	if thumbPath != "" {
+		recordThumb(docGuid, resPath, thumbPath)
+	}
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+var (
+	insertToc      = flag.Bool("insert-toc", false, "insert a table of contents linking the headings on top of long docs")
+	tocMinHeadings = flag.Int("tocMinHeadings", 3, "min headings of a doc to insert the table of contents")
+
+	mdLinkRe = regexp.MustCompile(`!?\[([^\]]*)\]\([^)]*\)`)
+)
+
+// insertTOC puts the table of contents of the headings on top of the markdown.
+func insertTOC(markdown string) string {
+	sections := splitSections(markdown, 6)[1:]
+	if len(sections) < *tocMinHeadings {
+		return markdown
+	}
+	minLevel := 6
+	for _, sec := range sections {
+		if sec.level < minLevel {
+			minLevel = sec.level
+		}
+	}
+	var b strings.Builder
+	b.WriteString("**目录**\n\n")
+	slugs := make(map[string]int)
+	for _, sec := range sections {
+		fmt.Fprintf(&b, "%s- [%s](#%s)\n", strings.Repeat("  ", sec.level-minLevel), headingText(sec.title), headingSlug(sec.title, slugs))
+	}
+	return b.String() + "\n" + markdown
+}
+
+// headingText drops the links and emphasis of a heading.
+func headingText(title string) string {
+	title = mdLinkRe.ReplaceAllString(title, "$1")
+	return strings.TrimSpace(strings.NewReplacer("**", "", "__", "", "`", "", "~~", "").Replace(title))
+}
+
+// headingSlug returns the anchor of the heading like github and most renderers: lower case,
+// punctuation dropped, spaces as -, and -1, -2 appended to the repeated ones.
+func headingSlug(title string, seen map[string]int) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(headingText(title)) {
+		switch {
+		case unicode.IsLetter(r) || unicode.IsDigit(r) || r == '-' || r == '_':
+			b.WriteRune(r)
+		case r == ' ':
+			b.WriteRune('-')
+		}
+	}
+	slug := b.String()
+	if n, ok := seen[slug]; ok {
+		seen[slug] = n + 1
+		return fmt.Sprintf("%s-%d", slug, n+1)
+	}
+	seen[slug] = 0
+	return slug
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	md "github.com/JohannesKaufmann/html-to-markdown"
+	"github.com/PuerkitoBio/goquery"
+)
+
+const todosFile = "todos.md"
+
+var (
+	todos = flag.Bool("todos", false, "collect the unchecked tasks of all docs into todos.md")
+
+	// - [ ] task, * [ ] task or 1. [ ] task
+	openTaskRe = regexp.MustCompile(`^\s*(?:[-*+]|\d+[.)])\s+\[ \]\s+(.+)$`)
+)
+
+// wizTodos converts the wiz todo labels, a checkbox image before the text, into task markers.
+func wizTodos() md.Plugin {
+	return func(c *md.Converter) []md.Rule {
+		return []md.Rule{{
+			Filter: []string{"label"},
+			Replacement: func(content string, selec *goquery.Selection, opt *md.Options) *string {
+				var text string
+				switch {
+				case selec.HasClass("wiz-todo-label-checked"):
+					text = "[x] " + strings.TrimSpace(content)
+				case selec.HasClass("wiz-todo-label-unchecked"):
+					text = "[ ] " + strings.TrimSpace(content)
+				default:
+					return &content
+				}
+				// wiz todos are mostly paragraphs, make them list items
+				if selec.Closest("li").Length() == 0 {
+					text = "- " + text
+				}
+				return &text
+			},
+		}, {
+			Filter: []string{"img"},
+			Replacement: func(content string, selec *goquery.Selection, opt *md.Options) *string {
+				if selec.HasClass("wiz-todo-img") {
+					return md.String("")
+				}
+				// imageAlts
+				return nil
+			},
+		}}
+	}
+}
+
+// writeTodos writes todos.md with the unchecked tasks of the exported docs, linking their docs.
+func writeTodos(root string) error {
+	var buf bytes.Buffer
+	buf.WriteString("# Todos\n")
+	for _, entry := range buildManifest().Docs {
+		files := entry.Parts
+		if len(files) == 0 {
+			files = []string{entry.Path}
+		}
+		var tasks []string
+		for _, file := range files {
+			bs, err := ioutil.ReadFile(localPath(root, file))
+			if err != nil {
+				continue
+			}
+			for _, line := range markdownLines(string(bs)) {
+				if m := openTaskRe.FindStringSubmatch(strings.TrimRight(line.text, "\n")); m != nil && !line.code {
+					tasks = append(tasks, m[1])
+				}
+			}
+		}
+		if len(tasks) == 0 {
+			continue
+		}
+		fmt.Fprintf(&buf, "\n## [%s](<%s>)\n\n", entry.Title, entryLink(entry))
+		for _, task := range tasks {
+			fmt.Fprintf(&buf, "- [ ] %s\n", task)
+		}
+	}
+	if err := atomicWriteFile(filepath.Join(root, todosFile), buf.Bytes(), 0644); err != nil {
+		return WrapErr("WriteFile todos", err)
+	}
+	return nil
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"sync"
+
+	"github.com/GalaIO/wiz_export/wiz"
+)
+
+var (
+	// refreshMu makes one login at a time to refresh the token of tokenUser
+	refreshMu sync.Mutex
+	tokenUser *WizUser
+)
+
+func unauthorized(err error) bool {
+	var se *wiz.StatusError
+	return errors.As(err, &se) && se.Code == http.StatusUnauthorized
+}
+
+// refreshToken logs in again once the stale token is rejected. Concurrent requests
+// rejected with the same token wait for one login and share its token.
+func refreshToken(stale string) (string, error) {
+	refreshMu.Lock()
+	defer refreshMu.Unlock()
+	if tokenUser == nil {
+		return "", errors.New("no login to refresh")
+	}
+	if token := tokenUser.CurrentToken(); token != stale {
+		return token, nil
+	}
+	if *password == "" {
+		return "", errors.New("token expired, no password to login again")
+	}
+	logInfof("\ttoken expired, login again\n")
+	fresh, err := Login(*userId, *password)
+	if err != nil {
+		return "", WrapErr("refresh token", err)
+	}
+	tokenUser.SetToken(fresh.Token)
+	return fresh.Token, nil
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// local image references of the exported markdown, like ![](<doc.assets/a.png>) or ![](<../doc.assets/a b.png>)
+var imageRefRe = regexp.MustCompile(`!\[[^\]]*\]\((?:<([^>]+)>|([^)\s]+))`)
+
+type verifyReport struct {
+	docs      int
+	resources int
+	problems  []string
+}
+
+func (r *verifyReport) problem(format string, args ...interface{}) {
+	r.problems = append(r.problems, fmt.Sprintf(format, args...))
+}
+
+// runVerify checks an export against its manifest and returns the exit code.
+// usage
+// wiz_export verify --output '/Users/xx/'
+func runVerify(args []string) int {
+	fs := flag.NewFlagSet("verify", flag.ExitOnError)
+	root := fs.String("output", ".", "export output to verify")
+	fs.Parse(args)
+
+	m, err := loadManifest(filepath.Join(*root, manifestFile))
+	if err != nil {
+		fmt.Println("load manifest err:", err)
+		return 2
+	}
+	if len(m.Docs) == 0 {
+		fmt.Println("no docs in", filepath.Join(*root, manifestFile))
+		return 2
+	}
+
+	report := new(verifyReport)
+	for _, entry := range m.Docs {
+		verifyDoc(*root, entry, report)
+	}
+	fmt.Printf("Verify info:\n\tdocs: %v\n\tresources: %v\n\tproblems: %v\n",
+		report.docs, report.resources, len(report.problems))
+	for _, p := range report.problems {
+		fmt.Println("\t" + p)
+	}
+	if len(report.problems) > 0 {
+		return 1
+	}
+	return 0
+}
+
+func verifyDoc(root string, entry *ManifestEntry, report *verifyReport) {
+	report.docs++
+	files := entry.Parts
+	if len(files) == 0 {
+		files = []string{entry.Path}
+	}
+	for _, file := range files {
+		bs, err := ioutil.ReadFile(localPath(root, file))
+		if err != nil {
+			report.problem("missing doc: %s (%s)", file, entry.DocGuid)
+			continue
+		}
+		// the hash covers the whole doc, not its split parts
+		if len(entry.Parts) == 0 && entry.Hash != "" && hashContent(bs) != entry.Hash {
+			report.problem("hash mismatch: %s (%s)", file, entry.DocGuid)
+		}
+		for _, str := range imageRefRe.FindAllStringSubmatch(string(bs), -1) {
+			ref := str[1] + str[2]
+			if strings.Contains(ref, "://") || strings.HasPrefix(ref, "data:") {
+				continue
+			}
+			if _, err := os.Stat(filepath.Join(filepath.Dir(localPath(root, file)), filepath.FromSlash(ref))); err != nil {
+				report.problem("broken image: %s in %s", ref, file)
+			}
+		}
+	}
+	for _, res := range entry.Resources {
+		report.resources++
+		if _, err := os.Stat(localPath(root, res)); err != nil {
+			report.problem("missing resource: %s (%s)", res, entry.DocGuid)
+		}
+	}
+}
+
+// localPath returns the file of a slash separated path relative to the output.
+func localPath(root, rel string) string {
+	return filepath.Join(root, filepath.FromSlash(rel))
+}
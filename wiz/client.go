@@ -0,0 +1,261 @@
+package wiz
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"regexp"
+
+	md "github.com/JohannesKaufmann/html-to-markdown"
+	"github.com/JohannesKaufmann/html-to-markdown/plugin"
+)
+
+// DefaultPageSize is the page size of ListDocs.
+const DefaultPageSize = 200
+
+// ResourceRe matches the images of the doc resources in the converted markdown, the file name
+// in its group.
+var ResourceRe = regexp.MustCompile("!\\[[^\\]]*\\]\\(index_files/(.*?)\\)")
+
+// Doer sends the http requests, like *http.Client, so that the apis can be tested against a mock.
+type Doer interface {
+	Do(req *http.Request) (*http.Response, error)
+}
+
+// FetchFunc gets the url with the token, accept is the Accept header if not empty.
+type FetchFunc func(ctx context.Context, url, token, accept string) ([]byte, error)
+
+type Client struct {
+	// HTTPClient sends the requests, http.DefaultClient if nil
+	HTTPClient Doer
+	// Server is the account server to login, DefaultServer if empty
+	Server string
+	// LoginFields maps the fields of User to the renamed fields of the login result
+	LoginFields map[string]string
+	// VerifyField is the login field of the verification code, authCode if empty
+	VerifyField string
+	// Fetch gets the apis and resources, Client.Get if nil. Set it to add retries or rate limits
+	Fetch FetchFunc
+	// Converter converts the doc html to markdown, a GitHub flavored one if nil
+	Converter *md.Converter
+	// User is the login of the kb to export
+	User *User
+
+	ctx context.Context
+}
+
+// ExportedDoc is a doc converted to markdown.
+type ExportedDoc struct {
+	Doc      *Doc
+	HTML     []byte
+	Markdown string
+	// Resources are the file names of the images under index_files/ of the markdown
+	Resources []string
+}
+
+func NewClient(server string) *Client {
+	return &Client{Server: server}
+}
+
+// WithUser returns a copy of the client for the kb of the user.
+func (c *Client) WithUser(user *User) *Client {
+	k := *c
+	k.User = user
+	return &k
+}
+
+// WithContext returns a copy of the client whose requests are canceled with ctx.
+func (c *Client) WithContext(ctx context.Context) *Client {
+	k := *c
+	k.ctx = ctx
+	return &k
+}
+
+// Context returns the context of the requests, context.Background if not set.
+func (c *Client) Context() context.Context {
+	if c.ctx != nil {
+		return c.ctx
+	}
+	return context.Background()
+}
+
+func (c *Client) httpClient() Doer {
+	if c.HTTPClient != nil {
+		return c.HTTPClient
+	}
+	return http.DefaultClient
+}
+
+// Get gets the url without retries, failed statuses are returned as *StatusError with the response.
+func (c *Client) Get(ctx context.Context, url, token, accept string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("X-Wiz-Token", token)
+	if accept != "" {
+		req.Header.Set("Accept", accept)
+	}
+	resp, err := c.httpClient().Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	rs, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, &StatusError{Code: resp.StatusCode, Status: resp.Status, Header: resp.Header, Body: rs}
+	}
+	return rs, nil
+}
+
+func (c *Client) fetch(url, accept string) ([]byte, error) {
+	if c.Fetch != nil {
+		return c.Fetch(c.Context(), url, c.User.CurrentToken(), accept)
+	}
+	return c.Get(c.Context(), url, c.User.CurrentToken(), accept)
+}
+
+// fetchResult fetches the api into a result with a ResultCode, checking the code.
+func (c *Client) fetchResult(api, url string, result interface{ check(api string) error }) error {
+	bs, err := c.fetch(url, "")
+	if err != nil {
+		return wrapErr("fetch "+api, err)
+	}
+	if err := json.Unmarshal(bs, result); err != nil {
+		return wrapErr("Unmarshal "+api+" result", err)
+	}
+	return result.check(api)
+}
+
+// DocPage lists count docs of the folder from start, ordered by the created time.
+func (c *Client) DocPage(folder string, start, count int) ([]*Doc, error) {
+	result := new(DocListResult)
+	err := c.fetchResult("folder", fmt.Sprintf("%s/ks/note/list/category/%s?start=%d&count=%d&category=%s&orderBy=created",
+		c.User.KbServer, c.User.KbGuid, start, count, url.PathEscape(folder)), result)
+	return result.Result, err
+}
+
+// ListDocs lists all the docs of the folder, like /日记/, page by page.
+func (c *Client) ListDocs(folder string) ([]*Doc, error) {
+	var docs []*Doc
+	for start := 0; ; start += DefaultPageSize {
+		page, err := c.DocPage(folder, start, DefaultPageSize)
+		if err != nil {
+			return nil, err
+		}
+		docs = append(docs, page...)
+		if len(page) < DefaultPageSize {
+			return docs, nil
+		}
+	}
+}
+
+// Categories lists all the folders of the kb, like /日记/2023/.
+func (c *Client) Categories() ([]string, error) {
+	result := new(CategoryResult)
+	err := c.fetchResult("categories", fmt.Sprintf("%s/ks/category/all/%s", c.User.KbServer, c.User.KbGuid), result)
+	return result.Result, err
+}
+
+// Groups lists the team kbs of the user, on the account server.
+func (c *Client) Groups() ([]*Group, error) {
+	result := new(GroupListResult)
+	err := c.fetchResult("groups", c.accountURL("/as/user/groups"), result)
+	return result.Result, err
+}
+
+// Collection fetches the share collection with its groups of docs.
+func (c *Client) Collection(id string) (*Collection, error) {
+	result := new(CollectionResult)
+	err := c.fetchResult("collection", fmt.Sprintf("%s/ks/share/collection/%s/%s",
+		c.User.KbServer, c.User.KbGuid, url.PathEscape(id)), result)
+	if err == nil && result.Result == nil {
+		err = fmt.Errorf("fetch collection, no collection %s", id)
+	}
+	return result.Result, err
+}
+
+// DocInfo fetches the title, folder and times of the doc, without its content.
+func (c *Client) DocInfo(docGuid string) (*Doc, error) {
+	result := new(DocInfoResult)
+	err := c.fetchResult("doc info", fmt.Sprintf("%s/ks/note/download/%s/%s?downloadInfo=1&downloadData=0",
+		c.User.KbServer, c.User.KbGuid, docGuid), result)
+	if err == nil && result.Info == nil {
+		err = fmt.Errorf("fetch doc info, no info of %s", docGuid)
+	}
+	return result.Info, err
+}
+
+// DocHTML fetches the html of the doc.
+func (c *Client) DocHTML(docGuid string) ([]byte, error) {
+	html, err := c.fetch(fmt.Sprintf("%s/ks/note/view/%s/%s?objType=document",
+		c.User.KbServer, c.User.KbGuid, docGuid), "")
+	return html, wrapErr("fetch doc", err)
+}
+
+// ExportDoc fetches the doc and converts it to markdown, its resources are downloaded by Resource.
+func (c *Client) ExportDoc(doc *Doc) (*ExportedDoc, error) {
+	html, err := c.DocHTML(doc.DocGuid)
+	if err != nil {
+		return nil, err
+	}
+	conv := c.Converter
+	if conv == nil {
+		conv = md.NewConverter("", true, nil)
+		conv.Use(plugin.GitHubFlavored())
+	}
+	markdown, err := conv.ConvertString(string(html))
+	if err != nil {
+		return nil, wrapErr("ConvertString", err)
+	}
+	return &ExportedDoc{Doc: doc, HTML: html, Markdown: markdown, Resources: ResourceNames(markdown)}, nil
+}
+
+// ResourceNames returns the file names of the images under index_files/ of the markdown, each once.
+func ResourceNames(markdown string) []string {
+	var names []string
+	seen := make(map[string]bool)
+	for _, str := range ResourceRe.FindAllStringSubmatch(markdown, -1) {
+		if !seen[str[1]] {
+			seen[str[1]] = true
+			names = append(names, str[1])
+		}
+	}
+	return names
+}
+
+// ResourceURL returns the download url of a doc resource.
+func (c *Client) ResourceURL(docGuid, name string) string {
+	return fmt.Sprintf("%s/ks/note/view/%s/%s/index_files/%s", c.User.KbServer, c.User.KbGuid, docGuid, name)
+}
+
+// Resource downloads a doc resource, accept is the Accept header if not empty.
+func (c *Client) Resource(docGuid, name, accept string) ([]byte, error) {
+	data, err := c.fetch(c.ResourceURL(docGuid, name), accept)
+	return data, wrapErr("fetch res", err)
+}
+
+// Attachments lists the attachments of the doc.
+func (c *Client) Attachments(docGuid string) ([]*Attachment, error) {
+	result := new(AttachmentListResult)
+	err := c.fetchResult("attachments", fmt.Sprintf("%s/ks/note/attachments/%s/%s", c.User.KbServer, c.User.KbGuid, docGuid), result)
+	return result.Result, err
+}
+
+// AttachmentURL returns the download url of a doc attachment.
+func (c *Client) AttachmentURL(docGuid, attGuid string) string {
+	return fmt.Sprintf("%s/ks/attachment/download/%s/%s/%s", c.User.KbServer, c.User.KbGuid, docGuid, attGuid)
+}
+
+// Attachment downloads a doc attachment, accept is the Accept header if not empty.
+func (c *Client) Attachment(docGuid, attGuid, accept string) ([]byte, error) {
+	data, err := c.fetch(c.AttachmentURL(docGuid, attGuid), accept)
+	return data, wrapErr("fetch attachment", err)
+}
@@ -0,0 +1,189 @@
+package wiz
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+// newTestClient returns a client of the kb kb1 on the server, with the token t1.
+func newTestClient(server *httptest.Server) *Client {
+	c := NewClient(server.URL)
+	c.HTTPClient = server.Client()
+	return c.WithUser(&User{KbServer: server.URL, KbGuid: "kb1", Token: "t1"})
+}
+
+func TestListDocs(t *testing.T) {
+	total := DefaultPageSize + 3
+	var pages int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/ks/note/list/category/kb1" || r.Header.Get("X-Wiz-Token") != "t1" {
+			http.NotFound(w, r)
+			return
+		}
+		atomic.AddInt32(&pages, 1)
+		start, _ := strconv.Atoi(r.URL.Query().Get("start"))
+		count, _ := strconv.Atoi(r.URL.Query().Get("count"))
+		result := &DocListResult{ResultCode: ResultCode{ReturnCode: 200}}
+		for i := start; i < start+count && i < total; i++ {
+			result.Result = append(result.Result, &Doc{DocGuid: fmt.Sprint("doc", i), Category: r.URL.Query().Get("category")})
+		}
+		json.NewEncoder(w).Encode(result)
+	}))
+	defer server.Close()
+
+	docs, err := newTestClient(server).ListDocs("/日记/")
+	if err != nil {
+		t.Fatalf("ListDocs: %v", err)
+	}
+	if len(docs) != total {
+		t.Fatalf("ListDocs got %d docs, want %d", len(docs), total)
+	}
+	if pages != 2 {
+		t.Errorf("ListDocs fetched %d pages, want 2", pages)
+	}
+	for i, doc := range docs {
+		if doc.DocGuid != fmt.Sprint("doc", i) || doc.Category != "/日记/" {
+			t.Fatalf("ListDocs doc %d = %+v", i, doc)
+		}
+	}
+}
+
+func TestListDocsCode(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"returnCode":403,"returnMessage":"no permission"}`))
+	}))
+	defer server.Close()
+
+	_, err := newTestClient(server).ListDocs("/")
+	var cerr *CodeError
+	if !errors.As(err, &cerr) || cerr.Code != 403 {
+		t.Errorf("ListDocs err = %v, want *CodeError 403", err)
+	}
+}
+
+func TestExportDoc(t *testing.T) {
+	html := `<html><body><h1>Title</h1><p>some <strong>bold</strong> text</p>` +
+		`<img src="index_files/a.png"><img src="index_files/b"><img src="index_files/a.png"></body></html>`
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/ks/note/view/kb1/doc1" || r.URL.Query().Get("objType") != "document" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(html))
+	}))
+	defer server.Close()
+
+	doc := &Doc{DocGuid: "doc1", Title: "Title"}
+	exported, err := newTestClient(server).ExportDoc(doc)
+	if err != nil {
+		t.Fatalf("ExportDoc: %v", err)
+	}
+	if exported.Doc != doc || string(exported.HTML) != html {
+		t.Errorf("ExportDoc doc or html not kept")
+	}
+	for _, want := range []string{"# Title", "some **bold** text", "![](index_files/a.png)", "![](index_files/b)"} {
+		if !strings.Contains(exported.Markdown, want) {
+			t.Errorf("ExportDoc markdown %q without %q", exported.Markdown, want)
+		}
+	}
+	if want := []string{"a.png", "b"}; !reflect.DeepEqual(exported.Resources, want) {
+		t.Errorf("ExportDoc resources = %v, want %v", exported.Resources, want)
+	}
+}
+
+func TestExportDocStatus(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "5")
+		http.Error(w, "gone", http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	_, err := newTestClient(server).ExportDoc(&Doc{DocGuid: "doc1"})
+	var serr *StatusError
+	if !errors.As(err, &serr) || serr.Code != http.StatusNotFound {
+		t.Fatalf("ExportDoc err = %v, want *StatusError 404", err)
+	}
+	if string(serr.Body) != "gone\n" || serr.Header.Get("Retry-After") != "5" {
+		t.Errorf("StatusError body %q, Retry-After %q, want the response", serr.Body, serr.Header.Get("Retry-After"))
+	}
+}
+
+func TestResourceNames(t *testing.T) {
+	tests := []struct {
+		markdown string
+		want     []string
+	}{
+		{"no images", nil},
+		{"![](index_files/a.png)", []string{"a.png"}},
+		{"![x](index_files/a.png) ![y](index_files/b.jpg) ![z](index_files/a.png)", []string{"a.png", "b.jpg"}},
+		{"![](https://example.com/a.png) [link](index_files/c.png)", nil},
+	}
+	for _, tt := range tests {
+		if got := ResourceNames(tt.markdown); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ResourceNames(%q) = %v, want %v", tt.markdown, got, tt.want)
+		}
+	}
+}
+
+func TestResource(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/ks/note/view/kb1/doc1/index_files/a.png":
+			if r.Header.Get("Accept") != "image/*" {
+				http.Error(w, "accept", http.StatusNotAcceptable)
+				return
+			}
+			w.Write([]byte("png data"))
+		case "/ks/attachment/download/kb1/doc1/att1":
+			w.Write([]byte("attachment data"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	c := newTestClient(server)
+	data, err := c.Resource("doc1", "a.png", "image/*")
+	if err != nil || string(data) != "png data" {
+		t.Errorf("Resource = %q, %v", data, err)
+	}
+	data, err = c.Attachment("doc1", "att1", "")
+	if err != nil || string(data) != "attachment data" {
+		t.Errorf("Attachment = %q, %v", data, err)
+	}
+	if _, err := c.Resource("doc1", "missing.png", ""); err == nil {
+		t.Errorf("Resource of a missing file succeeded")
+	}
+}
+
+func TestFetch(t *testing.T) {
+	c := NewClient("").WithUser(&User{KbServer: "https://kb", KbGuid: "kb1", Token: "t1"})
+	var urls []string
+	c.Fetch = func(ctx context.Context, url, token, accept string) ([]byte, error) {
+		if token != "t2" {
+			return nil, &StatusError{Code: http.StatusUnauthorized, Status: "401 Unauthorized"}
+		}
+		urls = append(urls, url)
+		return []byte(`{"returnCode":200,"result":["/a/","/a/b/"]}`), nil
+	}
+	c.User.SetToken("t2")
+	folders, err := c.Categories()
+	if err != nil {
+		t.Fatalf("Categories: %v", err)
+	}
+	if want := []string{"/a/", "/a/b/"}; !reflect.DeepEqual(folders, want) {
+		t.Errorf("Categories = %v, want %v", folders, want)
+	}
+	if want := []string{"https://kb/ks/category/all/kb1"}; !reflect.DeepEqual(urls, want) {
+		t.Errorf("Fetch urls = %v, want %v", urls, want)
+	}
+}
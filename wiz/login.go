@@ -0,0 +1,157 @@
+package wiz
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"reflect"
+	"strings"
+)
+
+// DefaultServer is the account server of wiz.cn.
+const DefaultServer = "https://as.wiz.cn"
+
+// VerifyError rejects a login until a verification code is given, like for a login from a new place.
+type VerifyError struct {
+	Code    string
+	Message string
+}
+
+func (e *VerifyError) Error() string {
+	return fmt.Sprintf("login needs a verification code, code: %s, msg: %s", e.Code, e.Message)
+}
+
+// the messages of the logins asking for a verification code
+var verifyKeywords = []string{"验证码", "二次验证", "安全验证", "captcha", "verification", "verify code"}
+
+// Login logs in to the account server, the user is kept by the client. A login asking for
+// a verification code fails with *VerifyError, to retry by LoginWithCode.
+func (c *Client) Login(userId, password string) (*User, error) {
+	return c.LoginWithCode(userId, password, "")
+}
+
+// LoginWithCode logs in with the verification code sent to the user, in the VerifyField of the request.
+func (c *Client) LoginWithCode(userId, password, code string) (*User, error) {
+	body := map[string]string{"userId": userId, "password": password}
+	if code != "" {
+		field := c.VerifyField
+		if field == "" {
+			field = "authCode"
+		}
+		body[field] = code
+	}
+	bs, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(c.Context(), http.MethodPost, c.loginURL(), bytes.NewReader(bs))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := c.httpClient().Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.New(resp.Status)
+	}
+	rs, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	user, err := ParseLoginResult(rs, c.LoginFields)
+	if err != nil {
+		return nil, err
+	}
+	c.User = user
+	return user, nil
+}
+
+func (c *Client) loginURL() string {
+	return c.accountURL("/as/user/login")
+}
+
+// accountURL returns the url of the api on the account server.
+func (c *Client) accountURL(api string) string {
+	server := c.Server
+	if server == "" {
+		server = DefaultServer
+	}
+	return strings.TrimRight(server, "/") + api
+}
+
+// ParseLoginResult parses the login response leniently: extra fields are ignored, the result may be
+// under result or data, field names match case insensitively without _ and -, and renames map
+// the fields of User to the renamed ones, like token -> accessToken.
+func ParseLoginResult(rs []byte, renames map[string]string) (*User, error) {
+	var resp map[string]interface{}
+	if err := json.Unmarshal(rs, &resp); err != nil {
+		return nil, err
+	}
+	fields := normalizeKeys(resp)
+	if code := fmt.Sprint(lookupField(fields, "returnCode", "code")); code != "200" && code != "<nil>" {
+		msg := fmt.Sprint(lookupField(fields, "returnMessage", "message", "msg"))
+		lower := strings.ToLower(msg)
+		for _, keyword := range verifyKeywords {
+			if strings.Contains(lower, keyword) {
+				return nil, &VerifyError{Code: code, Message: msg}
+			}
+		}
+		return nil, fmt.Errorf("login, code: %s, msg: %s", code, msg)
+	}
+	for _, key := range []string{"result", "data"} {
+		if result, ok := lookupField(fields, key).(map[string]interface{}); ok {
+			fields = normalizeKeys(result)
+			break
+		}
+	}
+
+	user := new(User)
+	v := reflect.ValueOf(user).Elem()
+	for i := 0; i < v.NumField(); i++ {
+		name := v.Type().Field(i).Tag.Get("json")
+		value := lookupField(fields, renames[name], name)
+		if value == nil {
+			continue
+		}
+		switch value.(type) {
+		case string, float64, bool:
+			v.Field(i).SetString(fmt.Sprint(value))
+		}
+	}
+	if user.Token == "" || user.KbServer == "" || user.KbGuid == "" {
+		return nil, errors.New("login result without token, kbServer or kbGuid, map them by --loginFields")
+	}
+	return user, nil
+}
+
+func normalizeKeys(m map[string]interface{}) map[string]interface{} {
+	n := make(map[string]interface{}, len(m))
+	for k, v := range m {
+		n[normalizeKey(k)] = v
+	}
+	return n
+}
+
+func normalizeKey(key string) string {
+	return strings.ToLower(strings.NewReplacer("_", "", "-", "").Replace(key))
+}
+
+// lookupField returns the value of the first key found.
+func lookupField(fields map[string]interface{}, keys ...string) interface{} {
+	for _, key := range keys {
+		if key == "" {
+			continue
+		}
+		if v, ok := fields[normalizeKey(key)]; ok && v != nil {
+			return v
+		}
+	}
+	return nil
+}
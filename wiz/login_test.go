@@ -0,0 +1,103 @@
+package wiz
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestLogin(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/as/user/login" {
+			http.NotFound(w, r)
+			return
+		}
+		var body map[string]string
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		switch {
+		case body["password"] != "secret":
+			w.Write([]byte(`{"returnCode":31001,"returnMessage":"invalid password"}`))
+		case body["userId"] == "new@place" && body["authCode"] == "":
+			w.Write([]byte(`{"returnCode":31004,"returnMessage":"请输入验证码"}`))
+		default:
+			w.Write([]byte(`{"returnCode":200,"result":{"token":"t1","kbServer":"https://kb","kbGuid":"kb1","displayName":"` + body["userId"] + `"}}`))
+		}
+	}))
+	defer server.Close()
+
+	c := NewClient(server.URL)
+	c.HTTPClient = server.Client()
+	user, err := c.Login("me@wiz", "secret")
+	if err != nil {
+		t.Fatalf("Login: %v", err)
+	}
+	if user.Token != "t1" || user.KbServer != "https://kb" || user.KbGuid != "kb1" || user.DisplayName != "me@wiz" {
+		t.Errorf("Login user = %+v", user)
+	}
+	if c.User != user {
+		t.Errorf("Login did not keep the user in the client")
+	}
+
+	if _, err := NewClient(server.URL).WithUser(nil).Login("me@wiz", "wrong"); err == nil {
+		t.Errorf("Login with a wrong password succeeded")
+	}
+
+	c = NewClient(server.URL)
+	c.HTTPClient = server.Client()
+	_, err = c.Login("new@place", "secret")
+	var verr *VerifyError
+	if !errors.As(err, &verr) {
+		t.Fatalf("Login from a new place err = %v, want *VerifyError", err)
+	}
+	if _, err := c.LoginWithCode("new@place", "secret", "123456"); err != nil {
+		t.Errorf("LoginWithCode: %v", err)
+	}
+}
+
+func TestLoginStatus(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "down", http.StatusBadGateway)
+	}))
+	defer server.Close()
+
+	c := NewClient(server.URL)
+	c.HTTPClient = server.Client()
+	if _, err := c.Login("me@wiz", "secret"); err == nil {
+		t.Errorf("Login on a failed status succeeded")
+	}
+	if c.User != nil {
+		t.Errorf("failed Login kept the user %+v", c.User)
+	}
+}
+
+func TestParseLoginResult(t *testing.T) {
+	tests := []struct {
+		name    string
+		rs      string
+		renames map[string]string
+		token   string
+		wantErr bool
+	}{
+		{"result", `{"returnCode":200,"result":{"token":"t","kbServer":"s","kbGuid":"g"}}`, nil, "t", false},
+		{"data", `{"code":200,"data":{"Token":"t","kb_server":"s","KB-Guid":"g"}}`, nil, "t", false},
+		{"renamed", `{"result":{"accessToken":"t","kbServer":"s","kbGuid":"g"}}`, map[string]string{"token": "accessToken"}, "t", false},
+		{"missing", `{"returnCode":200,"result":{"kbServer":"s","kbGuid":"g"}}`, nil, "", true},
+		{"code", `{"returnCode":500,"returnMessage":"error"}`, nil, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			user, err := ParseLoginResult([]byte(tt.rs), tt.renames)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseLoginResult err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && user.Token != tt.token {
+				t.Errorf("ParseLoginResult token = %q, want %q", user.Token, tt.token)
+			}
+		})
+	}
+}
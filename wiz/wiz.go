@@ -0,0 +1,153 @@
+// Package wiz is a client of the wiz note apis: login, list the docs of a kb and export them to markdown.
+package wiz
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+)
+
+type ResultCode struct {
+	ReturnCode    int    `json:"returnCode"`
+	ReturnMessage string `json:"returnMessage"`
+}
+
+// check returns the error of a result not ok.
+func (rc *ResultCode) check(api string) error {
+	if rc.ReturnCode != 200 {
+		return &CodeError{API: api, Code: rc.ReturnCode, Message: rc.ReturnMessage}
+	}
+	return nil
+}
+
+// CodeError is a result whose returnCode is not 200, like no permission of the kb.
+type CodeError struct {
+	API     string
+	Code    int
+	Message string
+}
+
+func (e *CodeError) Error() string {
+	return fmt.Sprintf("fetch %s, code: %v, msg: %s", e.API, e.Code, e.Message)
+}
+
+type User struct {
+	UserGuid    string `json:"userGuid"`
+	Email       string `json:"email"`
+	Mobile      string `json:"mobile"`
+	DisplayName string `json:"displayName"`
+	KbType      string `json:"kbType"`
+	KbServer    string `json:"kbServer"`
+	Token       string `json:"token"`
+	KbGuid      string `json:"kbGuid"`
+	KbName      string `json:"kbName"`
+}
+
+// tokenMu guards the tokens of the users, refreshed while exporting
+var tokenMu sync.RWMutex
+
+// CurrentToken returns the token, which may be refreshed by SetToken while exporting.
+func (u *User) CurrentToken() string {
+	tokenMu.RLock()
+	defer tokenMu.RUnlock()
+	return u.Token
+}
+
+func (u *User) SetToken(token string) {
+	tokenMu.Lock()
+	defer tokenMu.Unlock()
+	u.Token = token
+}
+
+type DocListResult struct {
+	ResultCode
+	Result []*Doc `json:"result"`
+}
+
+type Doc struct {
+	DocGuid         string `json:"docGuid"`
+	Title           string `json:"title"`
+	Category        string `json:"category"`
+	AttachmentCount int    `json:"attachmentCount"`
+	Created         int    `json:"created"`
+	Accessed        int    `json:"accessed"`
+	Keywords        string `json:"keywords"`
+	CoverImage      string `json:"coverImage"`
+	Type            string `json:"type"`
+	Protected       int    `json:"protected"`
+	URL             string `json:"url"`
+	Pinned          int    `json:"pinned"`
+	Order           int    `json:"order"`
+	Color           string `json:"color"`
+	Modified        int    `json:"dataModified"`
+}
+
+type DocInfoResult struct {
+	ResultCode
+	Info *Doc `json:"info"`
+}
+
+type CategoryResult struct {
+	ResultCode
+	Result []string `json:"result"`
+}
+
+type AttachmentListResult struct {
+	ResultCode
+	Result []*Attachment `json:"result"`
+}
+
+type Attachment struct {
+	AttGuid  string `json:"attGuid"`
+	Name     string `json:"name"`
+	DataSize int64  `json:"dataSize"`
+}
+
+type CollectionResult struct {
+	ResultCode
+	Result *Collection `json:"result"`
+}
+
+// Collection is a share collection, its docs in groups.
+type Collection struct {
+	Title  string             `json:"title"`
+	Groups []*CollectionGroup `json:"groups"`
+}
+
+type CollectionGroup struct {
+	Name string `json:"name"`
+	Docs []*Doc `json:"docs"`
+}
+
+type GroupListResult struct {
+	ResultCode
+	Result []*Group `json:"result"`
+}
+
+// Group is a team kb the user joined.
+type Group struct {
+	KbGuid   string `json:"kbGuid"`
+	KbServer string `json:"kbServer"`
+	Name     string `json:"name"`
+	BizName  string `json:"bizName"`
+}
+
+// StatusError is the response status of a failed request.
+type StatusError struct {
+	Code   int
+	Status string
+	// Header and Body are of the response, like its Retry-After and error message
+	Header http.Header
+	Body   []byte
+}
+
+func (e *StatusError) Error() string {
+	return e.Status
+}
+
+func wrapErr(errMsg string, err error) error {
+	if err != nil {
+		return fmt.Errorf("%s, err: %w", errMsg, err)
+	}
+	return nil
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"archive/zip"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+var (
+	snapshot    = flag.String("snapshot", "", "write a dated zip snapshot after the export: full, or incremental with only the added and modified docs")
+	snapshotDir = flag.String("snapshotDir", "", "folder of the snapshots, default <output>/_snapshots")
+	zipOutput   = flag.String("zip", "", "zip the exported docs and resources into the file after the export, like backup.zip")
+)
+
+// writeSnapshot zips the export into wiz-<date>.zip, one snapshot a day.
+func writeSnapshot(root string, diff *ManifestDiff) error {
+	dir := snapshotFolder(root)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return WrapErr("MkdirAll snapshot", err)
+	}
+
+	var files []string
+	switch *snapshot {
+	case "full":
+		var err error
+		if files, err = outputFiles(root); err != nil {
+			return err
+		}
+	case "incremental":
+		files = []string{manifestFile, diffReportFile}
+		for _, entries := range [][]*ManifestEntry{diff.Added, diff.Modified} {
+			for _, entry := range entries {
+				if len(entry.Parts) > 0 {
+					files = append(files, entry.Parts...)
+				} else {
+					files = append(files, entry.Path)
+				}
+				files = append(files, entry.Resources...)
+			}
+		}
+	default:
+		return fmt.Errorf("unknown snapshot: %s", *snapshot)
+	}
+
+	file := filepath.Join(dir, "wiz-"+time.Now().Format("2006-01-02")+".zip")
+	if err := zipFiles(file, root, files); err != nil {
+		return err
+	}
+	logInfof("Snapshot info:\n\tfile: %s\n\tfiles: %v\n", file, len(files))
+	return nil
+}
+
+// writeZip zips all the files of the export into --zip, keeping the folders.
+func writeZip(root string) error {
+	files, err := outputFiles(root)
+	if err != nil {
+		return err
+	}
+	if err := zipFiles(*zipOutput, root, files); err != nil {
+		return err
+	}
+	logInfof("Zip info:\n\tfile: %s\n\tfiles: %v\n", *zipOutput, len(files))
+	return nil
+}
+
+// snapshotFolder returns the folder of the snapshots of the kb folder root.
+func snapshotFolder(root string) string {
+	if *snapshotDir != "" {
+		return *snapshotDir
+	}
+	return filepath.Join(root, "_snapshots")
+}
+
+// outputFiles returns the exported files under root relative to the output, without the
+// snapshots, the zips of the kb folder, the caches and the state of the incremental exports.
+func outputFiles(root string) ([]string, error) {
+	var files []string
+	skips := make(map[string]bool)
+	for _, file := range []string{snapshotFolder(root), *zipOutput, filepath.Join(root, ".cache"), filepath.Join(root, stateFile)} {
+		if file == "" {
+			continue
+		}
+		if abs, err := filepath.Abs(file); err == nil {
+			skips[abs] = true
+		}
+	}
+	absRoot, _ := filepath.Abs(root)
+	err := filepath.Walk(root, func(file string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		abs, _ := filepath.Abs(file)
+		skip := skips[abs] || skips[strings.TrimSuffix(abs, ".tmp")] ||
+			// the zips written into the kb folder before, like backup.zip
+			(filepath.Dir(abs) == absRoot && strings.HasSuffix(info.Name(), ".zip")) ||
+			// the temp files of atomicWriteFile
+			(strings.HasPrefix(info.Name(), ".wiz-") && strings.HasSuffix(info.Name(), ".tmp"))
+		if skip {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !info.IsDir() {
+			files = append(files, relPath(file))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, WrapErr("walk output", err)
+	}
+	return files, nil
+}
+
+// zipFiles writes the files, slash separated and relative to root, into the zip archive.
+func zipFiles(zipPath, root string, files []string) error {
+	tmp := zipPath + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return WrapErr("create zip", err)
+	}
+	defer os.Remove(tmp)
+
+	w := zip.NewWriter(f)
+	seen := make(map[string]bool, len(files))
+	for _, name := range files {
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+		if err := addZipFile(w, root, name); err != nil {
+			f.Close()
+			return err
+		}
+	}
+	if err := w.Close(); err != nil {
+		f.Close()
+		return WrapErr("close zip", err)
+	}
+	if err := f.Close(); err != nil {
+		return WrapErr("close zip", err)
+	}
+	return os.Rename(tmp, zipPath)
+}
+
+func addZipFile(w *zip.Writer, root, name string) error {
+	src, err := os.Open(filepath.Join(root, filepath.FromSlash(name)))
+	if os.IsNotExist(err) {
+		// a resource failed to download
+		return nil
+	}
+	if err != nil {
+		return WrapErr("open zip file", err)
+	}
+	defer src.Close()
+	info, err := src.Stat()
+	if err != nil {
+		return WrapErr("stat zip file", err)
+	}
+	header, err := zip.FileInfoHeader(info)
+	if err != nil {
+		return WrapErr("zip header", err)
+	}
+	header.Name = strings.TrimPrefix(name, "/")
+	header.Method = zip.Deflate
+	dst, err := w.CreateHeader(header)
+	if err != nil {
+		return WrapErr("create zip entry", err)
+	}
+	if _, err := io.Copy(dst, src); err != nil {
+		return WrapErr("write zip entry", err)
+	}
+	return nil
+}